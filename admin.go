@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const minAdminTokenLength = 32
+
+// secretFlags are the flags /_admin/config leaves out, as knowing their
+// values would let a client forge tokens or signed URLs.
+var secretFlags = map[string]bool{
+	"admin-token": true,
+	"secret-key":  true,
+}
+
+// serverStats counts what the server has done since it started.
+type serverStats struct {
+	started  time.Time
+	requests atomic.Int64
+	bots     atomic.Int64
+	slow     atomic.Int64
+	bytes    atomic.Int64
+
+	// slowThreshold is -log-slow-requests; requests taking longer are
+	// counted as slow.
+	slowThreshold time.Duration
+}
+
+// botUserAgents are substrings of the User-Agent of crawlers and command
+// line tools, matched case-insensitively.
+var botUserAgents = []string{"bot", "crawler", "spider", "slurp", "curl/", "wget/", "python-requests/", "go-http-client/"}
+
+// isBot reports whether userAgent looks like a crawler or a script rather
+// than a browser.
+func isBot(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, s := range botUserAgents {
+		if strings.Contains(userAgent, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// countRequests adds every request and the bytes written for it to stats.
+func (st *serverStats) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		if st.slowThreshold > 0 && time.Since(start) >= st.slowThreshold {
+			st.slow.Add(1)
+		}
+		st.requests.Add(1)
+		if isBot(r.UserAgent()) {
+			st.bots.Add(1)
+		}
+		st.bytes.Add(lw.size)
+	})
+}
+
+// adminAPI serves the /_admin/ endpoints to clients that send the admin
+// token as a bearer token.
+type adminAPI struct {
+	token   string
+	srv     *server
+	stats   *serverStats
+	limiter *connLimiter
+	config  map[string]string
+}
+
+type cacheStats struct {
+	Enabled bool    `json:"enabled"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+type adminStats struct {
+	UptimeSeconds     int64            `json:"uptime_seconds"`
+	Requests          int64            `json:"requests"`
+	BotRequests       int64            `json:"bot_requests"`
+	SlowRequests      int64            `json:"slow_requests"`
+	BytesServed       int64            `json:"bytes_served"`
+	ActiveConnections int              `json:"active_connections"`
+	ListingCache      cacheStats       `json:"listing_cache"`
+	Downloads         map[string]int64 `json:"downloads,omitempty"`
+}
+
+// bearerAuthorized reports whether r carries token as its bearer token.
+func bearerAuthorized(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func (a *adminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !bearerAuthorized(r, a.token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var method string
+	var respond func() any
+	switch r.URL.Path {
+	case "/_admin/config":
+		method, respond = http.MethodGet, func() any { return a.config }
+	case "/_admin/stats":
+		method, respond = http.MethodGet, func() any { return a.currentStats() }
+	case "/_admin/cache/clear":
+		method, respond = http.MethodPost, a.clearCache
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body := respond()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (a *adminAPI) clearCache() any {
+	if a.srv.listingCache != nil {
+		a.srv.listingCache.clear()
+	}
+	return map[string]bool{"cleared": a.srv.listingCache != nil}
+}
+
+func (a *adminAPI) currentStats() adminStats {
+	stats := adminStats{
+		UptimeSeconds: int64(time.Since(a.stats.started).Seconds()),
+		Requests:      a.stats.requests.Load(),
+		BotRequests:   a.stats.bots.Load(),
+		SlowRequests:  a.stats.slow.Load(),
+		BytesServed:   a.stats.bytes.Load(),
+	}
+	if a.srv.downloads != nil {
+		stats.Downloads = a.srv.downloads.snapshot()
+	}
+	if a.limiter != nil {
+		stats.ActiveConnections = a.limiter.active()
+	}
+	if c := a.srv.listingCache; c != nil {
+		stats.ListingCache = cacheStats{Enabled: true, Hits: c.hits.Load(), Misses: c.misses.Load()}
+		if total := stats.ListingCache.Hits + stats.ListingCache.Misses; total > 0 {
+			stats.ListingCache.HitRate = float64(stats.ListingCache.Hits) / float64(total)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+var errEntryFound = errors.New("entry found")
+
+// isTarArchive reports whether name is a tar archive that can be browsed,
+// compressed or not.
+func isTarArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar.xz", ".txz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitArchivePath splits a path like /a/archive.tar.gz/src/main.c into
+// the archive and the path inside it, if some prefix of p is an archive.
+func (s *server) splitArchivePath(p string) (archive, inner string, ok bool) {
+	for i := 1; i < len(p); i++ {
+		if p[i] != '/' || !isTarArchive(p[:i]) {
+			continue
+		}
+		if s.isFile(p[:i]) {
+			return p[:i], p[i:], true
+		}
+	}
+	return "", "", false
+}
+
+// walkTar calls fn for every entry of the tar archive at p, with the entry
+// name as a cleaned absolute path. An error returned by fn stops the walk.
+func (s *server) walkTar(p string, fn func(name string, hdr *tar.Header, tr *tar.Reader) error) error {
+	f, err := s.root.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.HasSuffix(name, ".bz2"), strings.HasSuffix(name, ".tbz2"):
+		r = bzip2.NewReader(f)
+	case strings.HasSuffix(name, ".xz"), strings.HasSuffix(name, ".txz"):
+		if r, err = xz.NewReader(f); err != nil {
+			return err
+		}
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(path.Clean("/"+hdr.Name), hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// serveArchive shows the directory inner of the archive at archivePath as
+// a listing, or streams the file inner from it. Entries inside archives get
+// the same -blocklist-exts, -disable-listing and -max-dir-depth checks as
+// files on disk.
+func (s *server) serveArchive(w http.ResponseWriter, r *http.Request, archivePath, inner string) {
+	inner = path.Clean("/" + inner)
+	full := strings.TrimSuffix(archivePath+inner, "/")
+	listing := strings.HasSuffix(r.URL.Path, "/")
+	switch {
+	case s.blocklist.blockedPath(full):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	case s.tooDeep(full, listing):
+		http.Error(w, "Maximum directory depth exceeded.", http.StatusForbidden)
+		return
+	case listing && s.listingDisabled(full):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if listing {
+		s.renderTarListing(w, r, archivePath, inner)
+		return
+	}
+	s.serveTarEntry(w, r, archivePath, inner)
+}
+
+// renderTarListing renders the entries of the directory dir inside the
+// archive at archivePath with the listing template.
+func (s *server) renderTarListing(w http.ResponseWriter, r *http.Request, archivePath, dir string) {
+	global, request, err := s.listingFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	entries := map[string]FileInfo{}
+	found := dir == "/"
+	err = s.walkTar(archivePath, func(name string, hdr *tar.Header, _ *tar.Reader) error {
+		if name == dir {
+			found = true
+			return nil
+		}
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			return nil
+		}
+		found = true
+		child, deeper, _ := strings.Cut(rest, "/")
+		if s.blocklist.blocked(child) {
+			return nil
+		}
+		isDir := deeper != "" || hdr.Typeflag == tar.TypeDir
+		if _, seen := entries[child]; seen && deeper != "" {
+			return nil
+		}
+		fi := FileInfo{Name: child, IsDir: isDir, ModTime: hdr.ModTime}
+		p := archivePath + prefix + child
+		if isDir {
+			p += "/"
+		} else {
+			fi.Size = hdr.Size
+			fi.IsAudio = isAudioFile(child)
+			fi.IsImage = isImageFile(child)
+			fi.Extension = path.Ext(child)
+			fi.IsSymlink = hdr.Typeflag == tar.TypeSymlink
+			fi.SymlinkTarget = hdr.Linkname
+		}
+		fi.Path = s.urlPath(p)
+		entries[child] = fi
+		return nil
+	})
+	if err != nil {
+		log.Printf("Could not read archive %s: %v", archivePath, err)
+		http.Error(w, "Error reading archive", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, fi := range entries {
+		files = append(files, fi)
+	}
+	sortFiles(files)
+
+	data := s.listingData(strings.TrimSuffix(archivePath+dir, "/"), filterFiles(files, global, request))
+	data.Filters = append(append([]string{}, global...), request...)
+	if dir == "/" {
+		// the archive's parent is where the archive itself is listed
+		data.ParentPath = s.urlPath(strings.TrimSuffix(path.Dir(archivePath), "/") + "/")
+	}
+	if wantsTextListing(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(textListing(data.Files))
+		return
+	}
+	s.hideFileExtensions(data.Files)
+	data.Nonce = s.setCSP(w)
+	var buf bytes.Buffer
+	if err := s.listing.get().Execute(&buf, data); err != nil {
+		log.Printf("Could not render listing for %s: %v", archivePath+dir, err)
+		http.Error(w, "Error rendering directory listing", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// serveTarEntry streams the file name from the archive at archivePath.
+// Tar archives have no index, so this reads the archive up to the entry.
+func (s *server) serveTarEntry(w http.ResponseWriter, r *http.Request, archivePath, name string) {
+	isDir := false
+	err := s.walkTar(archivePath, func(entry string, hdr *tar.Header, tr *tar.Reader) error {
+		if entry != name && !strings.HasPrefix(entry, name+"/") {
+			return nil
+		}
+		if entry != name || hdr.Typeflag == tar.TypeDir {
+			isDir = true
+			return errEntryFound
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		contentType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(hdr.Size, 10))
+		w.Header().Set("Last-Modified", hdr.ModTime.UTC().Format(http.TimeFormat))
+		if r.Method != http.MethodHead {
+			io.Copy(w, tr)
+		}
+		return errEntryFound
+	})
+	switch {
+	case isDir:
+		http.Redirect(w, r, path.Base(archivePath+name)+"/", http.StatusMovedPermanently)
+	case err == errEntryFound:
+	case err != nil:
+		log.Printf("Could not read archive %s: %v", archivePath, err)
+		http.Error(w, "Error reading archive", http.StatusInternalServerError)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// ogg comment headers carry cover art too, so allow for a decent sized one
+const maxOggCommentBytes = 16 << 20
+
+// the moov atom of an M4A file holds its sample tables next to the tags, and
+// those grow with the length of the file
+const maxMP4MoovBytes = 64 << 20
+
+// audioMeta holds the tags read from an audio file.
+type audioMeta struct {
+	Title     string
+	Artist    string
+	Album     string
+	Track     string
+	Cover     []byte
+	CoverMIME string
+}
+
+// readAudioMeta reads the ID3v2 tag of an MP3 file, the Vorbis comments of
+// a FLAC, Ogg Vorbis or Opus file or the iTunes tags of an M4A file. Cover
+// art is only loaded when withCover is set.
+func readAudioMeta(r io.Reader, name string, withCover bool) (*audioMeta, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".flac":
+		return readFLACMeta(r, withCover)
+	case ".m4a":
+		return readMP4Meta(r, withCover)
+	case ".ogg", ".oga", ".opus":
+		return readOggMeta(r, withCover)
+	default:
+		return readID3Meta(r, withCover)
+	}
+}
+
+func (s *server) readAudioMeta(p string, withCover bool) (*audioMeta, error) {
+	f, err := s.root.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readAudioMeta(f, p, withCover)
+}
+
+// handleCover serves the cover art embedded in the audio file at the path
+// following /_cover.
+func (s *server) handleCover(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_cover"))
+	if !isAudioFile(p) {
+		http.NotFound(w, r)
+		return
+	}
+	meta, err := s.readAudioMeta(p, true)
+	if err != nil || meta.Cover == nil {
+		http.NotFound(w, r)
+		return
+	}
+	ctype := meta.CoverMIME
+	if !strings.HasPrefix(ctype, "image/") {
+		ctype = "image/jpeg"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Write(meta.Cover)
+}
+
+func readID3Meta(r io.Reader, withCover bool) (*audioMeta, error) {
+	frames := []string{"Title", "Artist", "Album/Movie/Show title", "Track number/Position in set"}
+	if withCover {
+		frames = append(frames, "Attached picture")
+	}
+	tag, err := id3v2.ParseReader(r, id3v2.Options{Parse: true, ParseFrames: frames})
+	if err != nil {
+		return nil, err
+	}
+	meta := &audioMeta{
+		Title:  tag.Title(),
+		Artist: tag.Artist(),
+		Album:  tag.Album(),
+		Track:  tag.GetTextFrame(tag.CommonID("Track number/Position in set")).Text,
+	}
+	if withCover {
+		for _, f := range tag.GetFrames(tag.CommonID("Attached picture")) {
+			if pic, ok := f.(id3v2.PictureFrame); ok {
+				meta.Cover = pic.Picture
+				meta.CoverMIME = pic.MimeType
+				break
+			}
+		}
+	}
+	return meta, nil
+}
+
+func readFLACMeta(r io.Reader, withCover bool) (*audioMeta, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	meta := &audioMeta{}
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, err
+		}
+		last := hdr[0]&0x80 != 0
+		blockType := hdr[0] & 0x7f
+		size := int(hdr[1])<<16 | int(hdr[2])<<8 | int(hdr[3])
+
+		// 4 is VORBIS_COMMENT, 6 is PICTURE
+		if blockType == 4 || (blockType == 6 && withCover && meta.Cover == nil) {
+			block := make([]byte, size)
+			if _, err := io.ReadFull(br, block); err != nil {
+				return nil, err
+			}
+			if blockType == 4 {
+				parseVorbisComment(block, meta, withCover)
+			} else {
+				parseFLACPicture(block, meta)
+			}
+		} else if _, err := br.Discard(size); err != nil {
+			return nil, err
+		}
+		if last {
+			return meta, nil
+		}
+	}
+}
+
+// readOggMeta reads the comment header, which is the second packet of an
+// Ogg Vorbis or Opus stream.
+func readOggMeta(r io.Reader, withCover bool) (*audioMeta, error) {
+	br := bufio.NewReader(r)
+	var packet []byte
+	packets := 0
+	for packets < 2 {
+		var hdr [27]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return nil, err
+		}
+		if string(hdr[:4]) != "OggS" {
+			return nil, errors.New("not an Ogg file")
+		}
+		segments := make([]byte, hdr[26])
+		if _, err := io.ReadFull(br, segments); err != nil {
+			return nil, err
+		}
+		for _, n := range segments {
+			seg := make([]byte, n)
+			if _, err := io.ReadFull(br, seg); err != nil {
+				return nil, err
+			}
+			if packets == 1 {
+				packet = append(packet, seg...)
+				if len(packet) > maxOggCommentBytes {
+					return nil, errors.New("ogg comment header too large")
+				}
+			}
+			// a segment shorter than 255 bytes ends the packet
+			if n < 255 {
+				packets++
+				if packets == 2 {
+					break
+				}
+			}
+		}
+	}
+
+	meta := &audioMeta{}
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		parseVorbisComment(packet[7:], meta, withCover)
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		parseVorbisComment(packet[8:], meta, withCover)
+	default:
+		return nil, errors.New("no comment header found")
+	}
+	return meta, nil
+}
+
+// readMP4Meta reads the iTunes tags in moov/udta/meta/ilst of an M4A file.
+// The atoms before moov, usually the audio itself, are skipped over.
+func readMP4Meta(r io.Reader, withCover bool) (*audioMeta, error) {
+	for {
+		typ, size, err := readMP4AtomHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "moov" {
+			if size < 0 || size > maxMP4MoovBytes {
+				return nil, errors.New("moov atom too large")
+			}
+			moov := make([]byte, size)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, err
+			}
+			return parseMP4Tags(moov, withCover), nil
+		}
+		if size < 0 {
+			return nil, errors.New("no moov atom found")
+		}
+		if seeker, ok := r.(io.Seeker); ok {
+			_, err = seeker.Seek(size, io.SeekCurrent)
+		} else {
+			_, err = io.CopyN(io.Discard, r, size)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readMP4AtomHeader reads the header of the next atom and returns its type
+// and the size of its contents, -1 when it runs to the end of the file.
+func readMP4AtomHeader(r io.Reader) (string, int64, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return "", 0, err
+	}
+	typ := string(hdr[4:])
+	size := int64(binary.BigEndian.Uint32(hdr[:4]))
+	switch size {
+	case 0:
+		return typ, -1, nil
+	case 1:
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return "", 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(hdr[:])) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, errors.New("invalid atom size")
+	}
+	return typ, size, nil
+}
+
+// parseMP4Tags reads the title, artist, album, track number and cover out
+// of a moov atom.
+func parseMP4Tags(moov []byte, withCover bool) *audioMeta {
+	meta := &audioMeta{}
+	m := mp4Child(mp4Child(moov, "udta"), "meta")
+	// meta is usually a full atom, with a version and flags before its
+	// children, but QuickTime writes it without them
+	if len(m) >= 8 && string(m[4:8]) != "hdlr" {
+		m = m[4:]
+	}
+	mp4Atoms(mp4Child(m, "ilst"), func(typ string, item []byte) {
+		data := mp4Child(item, "data")
+		if len(data) < 8 {
+			return
+		}
+		// a data atom starts with the type of its value and a locale
+		kind, value := binary.BigEndian.Uint32(data)&0xffffff, data[8:]
+		switch typ {
+		case "\xa9nam":
+			setOnce(&meta.Title, string(value))
+		case "\xa9ART":
+			setOnce(&meta.Artist, string(value))
+		case "\xa9alb":
+			setOnce(&meta.Album, string(value))
+		case "trkn":
+			if len(value) >= 4 && binary.BigEndian.Uint16(value[2:]) > 0 {
+				setOnce(&meta.Track, strconv.Itoa(int(binary.BigEndian.Uint16(value[2:]))))
+			}
+		case "covr":
+			if withCover && meta.Cover == nil {
+				meta.Cover = value
+				meta.CoverMIME = "image/jpeg"
+				if kind == 14 {
+					meta.CoverMIME = "image/png"
+				}
+			}
+		}
+	})
+	return meta
+}
+
+// mp4Atoms calls fn with the type and contents of each atom in b.
+func mp4Atoms(b []byte, fn func(typ string, body []byte)) {
+	for len(b) >= 8 {
+		size, hdr := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return
+			}
+			size, hdr = binary.BigEndian.Uint64(b[8:]), 16
+		}
+		if size < hdr || size > uint64(len(b)) {
+			return
+		}
+		fn(string(b[4:8]), b[hdr:size])
+		b = b[size:]
+	}
+}
+
+// mp4Child returns the contents of the first atom of type typ in b, or nil.
+func mp4Child(b []byte, typ string) []byte {
+	var found []byte
+	mp4Atoms(b, func(t string, body []byte) {
+		if found == nil && t == typ {
+			found = body
+		}
+	})
+	return found
+}
+
+// parseVorbisComment fills meta from a Vorbis comment block, keeping the
+// first value of every field.
+func parseVorbisComment(b []byte, meta *audioMeta, withCover bool) {
+	rd := bytes.NewReader(b)
+	next := func() ([]byte, bool) {
+		var n uint32
+		if binary.Read(rd, binary.LittleEndian, &n) != nil || int64(n) > int64(rd.Len()) {
+			return nil, false
+		}
+		buf := make([]byte, n)
+		io.ReadFull(rd, buf)
+		return buf, true
+	}
+
+	// vendor string
+	if _, ok := next(); !ok {
+		return
+	}
+	var count uint32
+	if binary.Read(rd, binary.LittleEndian, &count) != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		key, value, _ := strings.Cut(string(comment), "=")
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			setOnce(&meta.Title, value)
+		case "ARTIST":
+			setOnce(&meta.Artist, value)
+		case "ALBUM":
+			setOnce(&meta.Album, value)
+		case "TRACKNUMBER":
+			setOnce(&meta.Track, value)
+		case "METADATA_BLOCK_PICTURE":
+			if withCover && meta.Cover == nil {
+				if data, err := base64.StdEncoding.DecodeString(value); err == nil {
+					parseFLACPicture(data, meta)
+				}
+			}
+		}
+	}
+}
+
+// parseFLACPicture reads the image out of a FLAC PICTURE block.
+func parseFLACPicture(b []byte, meta *audioMeta) {
+	rd := bytes.NewReader(b)
+	var n uint32
+	field := func() ([]byte, bool) {
+		if binary.Read(rd, binary.BigEndian, &n) != nil || int64(n) > int64(rd.Len()) {
+			return nil, false
+		}
+		buf := make([]byte, n)
+		io.ReadFull(rd, buf)
+		return buf, true
+	}
+
+	// picture type
+	if binary.Read(rd, binary.BigEndian, &n) != nil {
+		return
+	}
+	mimeType, ok := field()
+	if !ok {
+		return
+	}
+	if _, ok := field(); !ok {
+		return
+	}
+	// width, height, colour depth and number of colours
+	if _, err := rd.Seek(16, io.SeekCurrent); err != nil {
+		return
+	}
+	data, ok := field()
+	if !ok {
+		return
+	}
+	meta.Cover = data
+	meta.CoverMIME = string(mimeType)
+}
+
+func setOnce(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
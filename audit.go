@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditLog appends a JSON line for every operation that changes files or
+// share links: one when it starts and one with its outcome, both with the
+// request ID, so an operation that never finished still shows up.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+type auditEntry struct {
+	log *auditLog
+
+	Time       time.Time `json:"time"`
+	ID         string    `json:"id"`
+	Phase      string    `json:"phase"`
+	IP         string    `json:"ip"`
+	Method     string    `json:"method"`
+	Action     string    `json:"action"`
+	Path       string    `json:"path"`
+	User       string    `json:"user"`
+	Status     int       `json:"status,omitempty"`
+	SizeBefore *int64    `json:"size_before,omitempty"`
+}
+
+func openAuditLog(file string) (*auditLog, error) {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{f: f}, nil
+}
+
+// begin records that r is about to perform action on the file at p, whose
+// size was sizeBefore (negative when it doesn't exist). A nil log records
+// nothing.
+func (a *auditLog) begin(r *http.Request, action, p string, sizeBefore int64) *auditEntry {
+	if a == nil {
+		return nil
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	e := &auditEntry{
+		log:    a,
+		Time:   time.Now().UTC(),
+		ID:     requestID(r.Context()),
+		Phase:  "intent",
+		IP:     ip,
+		Method: r.Method,
+		Action: action,
+		Path:   p,
+		// every operation that is audited needs the admin token
+		User: "admin",
+	}
+	if sizeBefore >= 0 {
+		e.SizeBefore = &sizeBefore
+	}
+	a.write(e)
+	return e
+}
+
+// finish records the status the operation ended with.
+func (e *auditEntry) finish(status int) {
+	if e == nil {
+		return
+	}
+	done := *e
+	done.Time = time.Now().UTC()
+	done.Phase = "done"
+	done.Status = status
+	e.log.write(&done)
+}
+
+func (a *auditLog) write(e *auditEntry) {
+	line, _ := json.Marshal(e)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		log.Printf("Could not write audit log: %v", err)
+	}
+}
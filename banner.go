@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// banner is the summary of the configuration printed at startup.
+type banner struct {
+	title string
+	lines []bannerLine
+}
+
+type bannerLine struct {
+	label   string
+	value   string
+	warning bool
+}
+
+// add adds a setting that is in effect.
+func (b *banner) add(label, value string) {
+	b.lines = append(b.lines, bannerLine{label: label, value: value})
+}
+
+// warn adds a setting worth a second look, like one that loosens security.
+func (b *banner) warn(label, value string) {
+	b.lines = append(b.lines, bannerLine{label: label, value: value, warning: true})
+}
+
+// print writes the banner to f, with colors when f is a terminal.
+func (b *banner) print(f *os.File) {
+	color := isTerminal(f)
+	fmt.Fprintln(f, b.title)
+	width := 0
+	for _, l := range b.lines {
+		width = max(width, len(l.label))
+	}
+	for _, l := range b.lines {
+		value := l.value
+		if color {
+			code := "32" // green
+			if l.warning {
+				code = "33" // yellow
+			}
+			value = "\x1b[" + code + "m" + value + "\x1b[0m"
+		}
+		fmt.Fprintf(f, "  %-*s %s\n", width+1, l.label+":", value)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
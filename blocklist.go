@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// blocklistFS refuses to open anything with one of the -blocklist-exts
+// extensions, or below a directory with one, and leaves those entries out
+// of directory listings.
+type blocklistFS struct {
+	http.FileSystem
+	exts map[string]bool
+}
+
+func (fsys blocklistFS) Open(name string) (http.File, error) {
+	if fsys.blockedPath(name) {
+		return nil, os.ErrPermission
+	}
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return blocklistFile{File: f, fsys: fsys}, nil
+}
+
+// blocked reports whether the file name has a blocked extension, ignoring
+// case; for a dotfile like .env the whole name is the extension.
+func (fsys blocklistFS) blocked(name string) bool {
+	return fsys.exts[strings.ToLower(path.Ext(name))]
+}
+
+// blockedPath reports whether p or one of the directories it is below is
+// blocked.
+func (fsys blocklistFS) blockedPath(p string) bool {
+	for _, elem := range strings.Split(path.Clean("/"+p), "/") {
+		if fsys.blocked(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+type blocklistFile struct {
+	http.File
+	fsys blocklistFS
+}
+
+func (f blocklistFile) Readdir(count int) ([]fs.FileInfo, error) {
+	for {
+		entries, err := f.File.Readdir(count)
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !f.fsys.blocked(entry.Name()) {
+				kept = append(kept, entry)
+			}
+		}
+		// an empty batch would be taken for the end of the directory
+		if count <= 0 || len(kept) > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// ReadDir is Readdir for callers that stat entries themselves.
+func (f blocklistFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	rd, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		infos, err := f.Readdir(count)
+		entries := make([]fs.DirEntry, len(infos))
+		for i, info := range infos {
+			entries[i] = fs.FileInfoToDirEntry(info)
+		}
+		return entries, err
+	}
+	for {
+		entries, err := rd.ReadDir(count)
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !f.fsys.blocked(entry.Name()) {
+				kept = append(kept, entry)
+			}
+		}
+		if count <= 0 || len(kept) > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// warnUnblocked warns about a .env file or .git directory in dir that
+// -blocklist-exts doesn't keep from being served.
+func warnUnblocked(dir string, exts map[string]bool) {
+	for _, name := range []string{".env", ".git"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil && !exts[name] {
+			log.Printf("Warning: %s is served from %s; add %s to -blocklist-exts to keep it private", name, dir, name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/buckket/go-blurhash"
+)
+
+const (
+	blurhashWidth       = 32
+	maxCachedBlurhashes = 1024
+
+	// maxBlurhashPixels keeps /_blurhash from decoding images so large
+	// that doing so would use up memory
+	maxBlurhashPixels = 50 << 20
+)
+
+var (
+	errImageTooLarge    = errors.New("image too large")
+	errUnsupportedImage = errors.New("unsupported image")
+)
+
+// blurhashJobs bounds how many images are decoded and hashed at once, as
+// both take most of a CPU.
+var blurhashJobs = make(chan struct{}, runtime.NumCPU())
+
+type blurhashResult struct {
+	Blurhash string `json:"blurhash"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// blurhashes caches computed blurhashes by path and modification time.
+type blurhashes struct {
+	mu      sync.Mutex
+	entries map[string]blurhashResult
+}
+
+func (c *blurhashes) get(key string) (blurhashResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, ok := c.entries[key]
+	return res, ok
+}
+
+func (c *blurhashes) put(key string, res blurhashResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= maxCachedBlurhashes {
+		c.entries = map[string]blurhashResult{}
+	}
+	c.entries[key] = res
+}
+
+// handleBlurhash returns the blurhash of the image at the path following
+// /_blurhash, computed from a copy scaled down to 32 pixels wide, for
+// placeholders shown while it loads.
+func (s *server) handleBlurhash(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_blurhash"))
+	if !isImageFile(p) {
+		http.Error(w, "Not an image", http.StatusBadRequest)
+		return
+	}
+	f, err := s.root.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := p + "@" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	res, ok := s.blurhashes.get(key)
+	if !ok {
+		select {
+		case blurhashJobs <- struct{}{}:
+		case <-r.Context().Done():
+			return
+		}
+		res, err = computeBlurhash(f)
+		<-blurhashJobs
+		switch {
+		case errors.Is(err, errImageTooLarge):
+			http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, errUnsupportedImage):
+			http.Error(w, "Unsupported image", http.StatusUnsupportedMediaType)
+			return
+		case err != nil:
+			log.Printf("Could not compute blurhash of %s: %v", p, err)
+			http.Error(w, "Error reading image", http.StatusInternalServerError)
+			return
+		}
+		s.blurhashes.put(key, res)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	json.NewEncoder(w).Encode(res)
+}
+
+// computeBlurhash decodes the image in f and hashes a copy of it scaled
+// down to blurhashWidth pixels wide.
+func computeBlurhash(f io.ReadSeeker) (blurhashResult, error) {
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return blurhashResult{}, errUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > maxBlurhashPixels {
+		return blurhashResult{}, errImageTooLarge
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return blurhashResult{}, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return blurhashResult{}, errUnsupportedImage
+	}
+
+	small := scaleDown(img, blurhashWidth)
+	hash, err := blurhash.Encode(4, 3, small)
+	if err != nil {
+		return blurhashResult{}, err
+	}
+	b := small.Bounds()
+	return blurhashResult{Blurhash: hash, Width: b.Dx(), Height: b.Dy()}, nil
+}
+
+// scaleDown samples img down to width pixels wide, keeping its aspect
+// ratio; images already that narrow are returned as they are.
+func scaleDown(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width {
+		return img
+	}
+	height := max(b.Dy()*width/b.Dx(), 1)
+	small := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			small.Set(x, y, img.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height))
+		}
+	}
+	return small
+}
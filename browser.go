@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// openBrowser opens url in the default browser once the server had a
+// moment to start accepting connections.
+func openBrowser(url string) {
+	time.Sleep(200 * time.Millisecond)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("Could not open a browser: %v", err)
+		return
+	}
+	go cmd.Wait()
+}
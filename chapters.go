@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// chaptersSuffix ends the name of the sidecar file holding the chapters of
+// the video with the same stem.
+const chaptersSuffix = ".chapters.json"
+
+type chapter struct {
+	StartMS int64  `json:"start_ms"`
+	Title   string `json:"title"`
+}
+
+// chaptersPath returns the path of the chapters sidecar of the video at p.
+func chaptersPath(p string) string {
+	return strings.TrimSuffix(p, path.Ext(p)) + chaptersSuffix
+}
+
+// readChapters returns the chapters of the video at p from its sidecar
+// file, or nil when it has none or it can't be parsed.
+func (s *server) readChapters(p string) []chapter {
+	f, err := s.root.Open(chaptersPath(p))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var chapters []chapter
+	if err := json.NewDecoder(io.LimitReader(f, 1<<20)).Decode(&chapters); err != nil {
+		return nil
+	}
+	return chapters
+}
+
+// handleChapters serves the chapters of the video at the path following
+// /_chapters as [{"start_ms":0,"title":"Intro"}, ...], for external players.
+func (s *server) handleChapters(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_chapters"))
+	if !isVideoFile(p) {
+		http.NotFound(w, r)
+		return
+	}
+	chapters := s.readChapters(p)
+	if chapters == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chapters)
+}
+
+// hideChapterFiles leaves out of files the chapters sidecars of the videos
+// listed next to them.
+func hideChapterFiles(files []FileInfo) []FileInfo {
+	videos := map[string]bool{}
+	for _, f := range files {
+		if !f.IsDir && isVideoFile(f.Name) {
+			videos[chaptersPath(f.Name)] = true
+		}
+	}
+	if len(videos) == 0 {
+		return files
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if f.IsDir || !videos[f.Name] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
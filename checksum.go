@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/zeebo/blake3"
+)
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"blake3": func() hash.Hash { return blake3.New() },
+}
+
+type checksumResult struct {
+	Algo string `json:"algo"`
+	Hex  string `json:"hex"`
+	Size int64  `json:"size"`
+	Name string `json:"name"`
+}
+
+// escapesRoot reports whether the raw request path p tries to climb out of
+// the served directory with "..".
+func escapesRoot(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// handleChecksum hashes the file at the path following /_checksum with
+// ?algo= (sha256 by default), streaming it so file size doesn't matter.
+func (s *server) handleChecksum(w http.ResponseWriter, r *http.Request) {
+	rel := strings.TrimPrefix(r.URL.Path, "/_checksum")
+	if escapesRoot(rel) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	p := path.Clean("/" + rel)
+
+	algo := r.URL.Query().Get("algo")
+	if algo == "" {
+		algo = "sha256"
+	}
+	newHash, ok := checksumAlgorithms[algo]
+	if !ok {
+		http.Error(w, "Unsupported algo, use sha256, sha1, md5 or blake3", http.StatusBadRequest)
+		return
+	}
+
+	f, err := s.root.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := newHash()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		log.Printf("Could not hash %s: %v", p, err)
+		http.Error(w, "Error reading file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(checksumResult{
+		Algo: algo,
+		Hex:  hex.EncodeToString(h.Sum(nil)),
+		Size: size,
+		Name: path.Base(p),
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxColorPixels keeps /_color from decoding images so large that doing so
+// would use up memory.
+const maxColorPixels = 50 << 20
+
+// colorJobs bounds how many images /_color decodes at once, as each can
+// take a few hundred megabytes.
+var colorJobs = make(chan struct{}, 2)
+
+// averageColor returns the average of a grid of samples across img, which
+// is close enough to its dominant color for a placeholder.
+func averageColor(img image.Image) string {
+	b := img.Bounds()
+	stepX, stepY := max(b.Dx()/64, 1), max(b.Dy()/64, 1)
+	var r, g, bl, n uint64
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r, g, bl, n = r+uint64(cr>>8), g+uint64(cg>>8), bl+uint64(cb>>8), n+1
+		}
+	}
+	if n == 0 {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r/n, g/n, bl/n)
+}
+
+// handleColor returns the average color of the image at the path following
+// /_color as {"hex":"#rrggbb"}, for placeholders shown while it loads.
+func (s *server) handleColor(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_color"))
+	if !isImageFile(p) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := s.root.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		http.Error(w, "Unsupported image", http.StatusUnsupportedMediaType)
+		return
+	}
+	if cfg.Width*cfg.Height > maxColorPixels {
+		http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Error reading image", http.StatusInternalServerError)
+		return
+	}
+	select {
+	case colorJobs <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	img, _, err := image.Decode(f)
+	<-colorJobs
+	if err != nil {
+		http.Error(w, "Unsupported image", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	json.NewEncoder(w).Encode(map[string]string{"hex": averageColor(img)})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// connLimiter lets at most cap(sem) requests be handled at the same time.
+// Listing pages keep a /_ws/ or /_events/ stream open for as long as they
+// are, so those count against a cap of their own in live instead.
+type connLimiter struct {
+	sem  chan struct{}
+	live chan struct{}
+}
+
+func newConnLimiter(n int) *connLimiter {
+	return &connLimiter{sem: make(chan struct{}, n), live: make(chan struct{}, n)}
+}
+
+// active returns the number of requests being handled.
+func (l *connLimiter) active() int {
+	return len(l.sem)
+}
+
+// limit answers requests with 503 Service Unavailable while the limit is
+// reached, instead of queueing them.
+func (l *connLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sem := l.sem
+		if isLiveUpdate(r.URL.Path) {
+			sem = l.live
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many connections, try again shortly", http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-sem }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLiveUpdate reports whether p is one of the live update streams.
+func isLiveUpdate(p string) bool {
+	return strings.HasPrefix(p, "/_ws/") || strings.HasPrefix(p, "/_events/")
+}
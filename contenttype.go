@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+	"mime"
+	"net/http"
+)
+
+// overrideContentType sets the Content-Type asked for with ?content_type=
+// on the response for the file at p. It reports false after answering the
+// request itself because the value is not a valid media type.
+func (s *server) overrideContentType(w http.ResponseWriter, r *http.Request, p string) bool {
+	contentType := r.URL.Query().Get("content_type")
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		http.Error(w, "Invalid content_type: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if s.verbose {
+		log.Printf("Serving %s as %s", p, contentType)
+	}
+	w.Header().Set("Content-Type", contentType)
+	return true
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+)
+
+// setCSP adds the Content-Security-Policy for -csp-mode to a page rendered
+// from one of the built-in templates, and returns the nonce its <script>
+// and <style> tags need in strict mode.
+func (s *server) setCSP(w http.ResponseWriter) string {
+	var nonce, inline string
+	switch s.cspMode {
+	case "strict":
+		b := make([]byte, 16)
+		rand.Read(b)
+		nonce = base64.StdEncoding.EncodeToString(b)
+		inline = "'nonce-" + nonce + "'"
+	case "permissive":
+		inline = "'unsafe-inline'"
+	default:
+		return ""
+	}
+	// connect-src is for the live updates of listings, object-src and
+	// frame-src for the PDF viewer
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src "+inline+"; style-src "+inline+
+		"; img-src 'self'; media-src 'self'; connect-src 'self'; object-src 'self'; frame-src 'self'")
+	return nonce
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// downloadCounter counts completed file downloads per path and saves the
+// counts to file every 30 seconds.
+type downloadCounter struct {
+	file string
+
+	mu     sync.Mutex
+	counts map[string]int64
+	dirty  bool
+}
+
+func loadDownloadCounter(file string) (*downloadCounter, error) {
+	c := &downloadCounter{file: file, counts: map[string]int64{}}
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &c.counts); err != nil {
+			return nil, err
+		}
+	}
+	go c.flushEvery(30 * time.Second)
+	return c, nil
+}
+
+func (c *downloadCounter) add(p string) {
+	c.mu.Lock()
+	c.counts[p]++
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// countDownload calls serve to send the file at p, counting it with
+// -stats-file when a GET gets all of it or a range.
+func (s *server) countDownload(w http.ResponseWriter, r *http.Request, p string, serve func(w http.ResponseWriter)) {
+	if s.downloads == nil || r.Method != http.MethodGet {
+		serve(w)
+		return
+	}
+	lw := &loggingResponseWriter{ResponseWriter: w}
+	serve(lw)
+	if lw.status == http.StatusOK || lw.status == http.StatusPartialContent {
+		s.downloads.add(p)
+	}
+}
+
+// snapshot returns a copy of the counts.
+func (c *downloadCounter) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for p, n := range c.counts {
+		counts[p] = n
+	}
+	return counts
+}
+
+// flush writes the counts to the file if they changed since the last
+// successful flush.
+func (c *downloadCounter) flush() error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(c.counts, "", "  ")
+	c.dirty = false
+	c.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(c.file, data)
+	}
+	if err != nil {
+		// try again next time, even if nothing else is downloaded
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return err
+}
+
+func (c *downloadCounter) flushEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := c.flush(); err != nil {
+			log.Printf("Could not save download stats: %v", err)
+		}
+	}
+}
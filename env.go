@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const envPrefix = "SIMPLE_HTTP_"
+
+// envFlags maps the environment variables that configure the server to
+// the flags they set.
+var envFlags = map[string]string{
+	envPrefix + "PORT":    "port",
+	envPrefix + "DIR":     "dir",
+	envPrefix + "VERBOSE": "verbose",
+}
+
+// unsupportedEnv are variables other tools configure simple HTTP servers
+// with that this server has nothing to map to, as it serves read only over
+// plain HTTP without logins.
+var unsupportedEnv = map[string]bool{
+	envPrefix + "AUTH":     true,
+	envPrefix + "TLS_CERT": true,
+	envPrefix + "TLS_KEY":  true,
+	envPrefix + "WRITABLE": true,
+}
+
+// loadEnvFile sets the KEY=value pairs in the file at name as environment
+// variables, leaving alone the ones already set. Blank lines and lines
+// starting with # are skipped, and values may be quoted.
+func loadEnvFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("%s:%d: want KEY=value", name, n)
+		}
+		value, err = envValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// envValue unquotes a double or single quoted value and strips a trailing
+// # comment from an unquoted one.
+func envValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := strings.LastIndex(value, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return strconv.Unquote(value[:end+1])
+	case strings.HasPrefix(value, "'"):
+		end := strings.LastIndex(value, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return value[1:end], nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// applyEnvFlags sets the flags that weren't given on the command line from
+// their SIMPLE_HTTP_ environment variables, and warns about variables with
+// that prefix that don't configure anything.
+func applyEnvFlags() {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, envPrefix) {
+			continue
+		}
+		name, ok := envFlags[key]
+		if unsupportedEnv[key] {
+			log.Printf("Warning: ignoring %s; this server has no authentication, TLS or write support", key)
+			continue
+		}
+		if !ok {
+			log.Printf("Warning: ignoring unknown environment variable %s", key)
+			continue
+		}
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			log.Fatalf("Invalid %s: %v", key, err)
+		}
+	}
+}
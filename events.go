@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// handleEvents streams the changes to the directory following /_events as
+// server-sent events, for clients that can't get a WebSocket through.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	dirPath := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_events"))
+	if !s.isDir(dirPath) {
+		http.NotFound(w, r)
+		return
+	}
+	if s.listingDisabled(dirPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if s.watcher == nil {
+		http.Error(w, "Directory watching is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// the stream stays open far longer than -write-timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	s.followDir(r.Context(), dirPath, func(events []dirEvent) error {
+		for _, ev := range events {
+			b, _ := json.Marshal(ev)
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	}, func() error {
+		if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+const maxFeedItems = 20
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+	Description string `xml:"description"`
+}
+
+type feedFile struct {
+	path string
+	info fs.FileInfo
+}
+
+// baseURL is the scheme and host the request was made to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// handleFeed serves an RSS feed of the most recently modified files below
+// ?path=, the whole served tree by default.
+func (s *server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	dirPath := path.Clean("/" + r.URL.Query().Get("path"))
+	if !s.isDir(dirPath) {
+		http.NotFound(w, r)
+		return
+	}
+	if s.listingDisabled(dirPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var files []feedFile
+	s.walkListed(dirPath, func(p string, info fs.FileInfo) error {
+		if r.Context().Err() != nil {
+			return r.Context().Err()
+		}
+		if !info.IsDir() && s.passesFilter(info.Name()) {
+			files = append(files, feedFile{path: p, info: info})
+		}
+		return nil
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].info.ModTime().After(files[j].info.ModTime()) })
+	files = files[:min(len(files), maxFeedItems)]
+
+	// the newest time alone would miss older items being removed or renamed
+	h := fnv.New64a()
+	for _, f := range files {
+		fmt.Fprintf(h, "%s\x00%d\x00", f.path, f.info.ModTime().UnixNano())
+	}
+	etag := fmt.Sprintf(`"%x"`, h.Sum64())
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	base := baseURL(r)
+	feed := rss{Version: "2.0", Channel: rssChannel{
+		Title:       s.feedTitle,
+		Link:        base + s.urlPath(dirPath),
+		Description: "Recently modified files in " + dirPath,
+	}}
+	for _, f := range files {
+		link := base + s.urlPath(f.path)
+		contentType := mime.TypeByExtension(strings.ToLower(path.Ext(f.path)))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       f.info.Name(),
+			Link:        link,
+			PubDate:     f.info.ModTime().UTC().Format(http.TimeFormat),
+			GUID:        link,
+			Description: formatFileSize(f.info.Size()) + ", " + contentType,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
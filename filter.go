@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// filterFlag collects the repeatable -filter glob flag.
+type filterFlag []string
+
+func (f *filterFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *filterFlag) Set(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q", pattern)
+	}
+	*f = append(*f, pattern)
+	return nil
+}
+
+// listingFilters returns the patterns files have to match to be listed for
+// r: those of -filter, and those of ?filter= for this request alone.
+func (s *server) listingFilters(r *http.Request) (global, request []string, err error) {
+	if q := r.URL.Query().Get("filter"); q != "" {
+		for _, pattern := range strings.Split(q, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, nil, fmt.Errorf("invalid filter %q", pattern)
+			}
+			request = append(request, pattern)
+		}
+	}
+	return s.filters, request, nil
+}
+
+// listFilteredFiles lists dir like listFiles, keeping only the files that
+// -filter and ?filter= let through, for the pages other than the listing
+// that show a directory. It answers r itself and returns false if it can't.
+func (s *server) listFilteredFiles(w http.ResponseWriter, r *http.Request, dirPath string, dir http.File) ([]FileInfo, bool) {
+	global, request, err := s.listingFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	files, err := s.listFiles(dirPath, dir)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return nil, false
+	}
+	return filterFiles(files, global, request), true
+}
+
+// passesFilter reports whether -filter lets the file name through, for the
+// endpoints that walk the tree.
+func (s *server) passesFilter(name string) bool {
+	return len(s.filters) == 0 || matchesAny(name, s.filters)
+}
+
+// filterFiles keeps the directories of files, and the files that match one
+// pattern of every non-empty set in patterns.
+func filterFiles(files []FileInfo, patterns ...[]string) []FileInfo {
+	kept := files[:0]
+	for _, f := range files {
+		if f.IsDir || matchesAll(f.Name, patterns) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func matchesAll(name string, sets [][]string) bool {
+	for _, set := range sets {
+		if len(set) > 0 && !matchesAny(name, set) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// gitignoreFS wraps a file system so that directory listings leave out
+// entries matched by .gitignore files in the listed directory or any of its
+// parents up to the served root, in any of the -overlay layers in roots.
+// Names listed in the directory's .nohide files are shown anyway.
+type gitignoreFS struct {
+	http.FileSystem
+	roots []string
+}
+
+func (fsys gitignoreFS) Open(name string) (http.File, error) {
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &gitignoreFile{File: f, dir: path.Clean("/" + name), roots: fsys.roots}, nil
+}
+
+type gitignoreFile struct {
+	http.File
+	dir    string
+	roots  []string
+	rules  []gitignoreRules
+	nohide map[string]bool
+}
+
+// gitignoreRules is a compiled .gitignore file together with the directory
+// (as a URL path) its patterns are relative to.
+type gitignoreRules struct {
+	base string
+	gi   *ignore.GitIgnore
+}
+
+func (f *gitignoreFile) Readdir(count int) ([]fs.FileInfo, error) {
+	f.loadRules()
+	for {
+		entries, err := f.File.Readdir(count)
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !f.ignored(entry.Name(), entry.IsDir()) {
+				kept = append(kept, entry)
+			}
+		}
+		// keep reading when a whole batch was filtered out, otherwise
+		// the caller would take an empty batch for the end of the directory
+		if count <= 0 || len(kept) > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// ReadDir is Readdir for callers that stat entries themselves.
+func (f *gitignoreFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	rd, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		infos, err := f.Readdir(count)
+		entries := make([]fs.DirEntry, len(infos))
+		for i, info := range infos {
+			entries[i] = fs.FileInfoToDirEntry(info)
+		}
+		return entries, err
+	}
+	f.loadRules()
+	for {
+		entries, err := rd.ReadDir(count)
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !f.ignored(entry.Name(), entry.IsDir()) {
+				kept = append(kept, entry)
+			}
+		}
+		if count <= 0 || len(kept) > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+func (f *gitignoreFile) loadRules() {
+	if f.rules == nil {
+		f.rules = loadGitignoreRules(f.roots, f.dir)
+		f.nohide = map[string]bool{}
+		for _, root := range f.roots {
+			for name := range loadNohide(filepath.Join(root, filepath.FromSlash(f.dir), ".nohide")) {
+				f.nohide[name] = true
+			}
+		}
+	}
+}
+
+func (f *gitignoreFile) ignored(name string, isDir bool) bool {
+	switch {
+	case name == ".nohide":
+		return true
+	case name == ".gitignore", f.nohide[name]:
+		return false
+	}
+	full := path.Join(f.dir, name)
+	if isDir {
+		full += "/"
+	}
+	for _, r := range f.rules {
+		rel := strings.TrimPrefix(full, strings.TrimSuffix(r.base, "/")+"/")
+		if r.gi.MatchesPath(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadNohide reads the names listed one per line in a .nohide file.
+func loadNohide(file string) map[string]bool {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names[line] = true
+		}
+	}
+	return names
+}
+
+// loadGitignoreRules collects the .gitignore files that apply to dir, from
+// dir itself up to the root of the served tree, in each of roots.
+func loadGitignoreRules(roots []string, dir string) []gitignoreRules {
+	rules := []gitignoreRules{}
+	for d := dir; ; d = path.Dir(d) {
+		for _, root := range roots {
+			gi, err := ignore.CompileIgnoreFile(filepath.Join(root, filepath.FromSlash(d), ".gitignore"))
+			if err == nil {
+				rules = append(rules, gitignoreRules{base: d, gi: gi})
+			}
+		}
+		if d == "/" {
+			break
+		}
+	}
+	return rules
+}
@@ -1,3 +1,23 @@
 module simplehttpserver
 
 go 1.22.5
+
+require (
+	github.com/bogem/id3v2/v2 v2.1.4
+	github.com/buckket/go-blurhash v1.1.0
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
+	github.com/ulikunitz/xz v0.5.17
+	github.com/yuin/goldmark v1.8.6
+	github.com/zeebo/blake3 v0.2.4
+	golang.org/x/crypto v0.33.0
+	golang.org/x/net v0.35.0
+	golang.org/x/time v0.8.0
+)
+
+require (
+	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
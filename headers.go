@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// headerFlag collects the repeatable -header "Name: Value" flag.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var pairs []string
+	for name, values := range h {
+		for _, v := range values {
+			pairs = append(pairs, name+": "+v)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (h headerFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("invalid header %q, want \"Name: Value\"", s)
+	}
+	http.Header(h).Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+// addHeaders adds headers to every response, leaving alone the headers the
+// handler set itself.
+func addHeaders(next http.Handler, headers http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&headerWriter{ResponseWriter: w, headers: headers}, r)
+	})
+}
+
+// headerWriter adds its headers just before the response header is sent,
+// once the handler has set everything it wants to.
+type headerWriter struct {
+	http.ResponseWriter
+	headers http.Header
+	written bool
+}
+
+func (w *headerWriter) addHeaders() {
+	if w.written {
+		return
+	}
+	w.written = true
+	h := w.ResponseWriter.Header()
+	for name, values := range w.headers {
+		if _, ok := h[name]; ok {
+			continue
+		}
+		for _, v := range values {
+			h.Add(name, v)
+		}
+	}
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	w.addHeaders()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	w.addHeaders()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerWriter) Flush() {
+	w.addHeaders()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *headerWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	return hijacker.Hijack()
+}
+
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
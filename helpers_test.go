@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	defer func(f string, loc *time.Location) { dateFormat, dateLocation = f, loc }(dateFormat, dateLocation)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	at := time.Date(2024, time.March, 5, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		format string
+		loc    *time.Location
+		want   string
+	}{
+		{"Jan 02, 2006", time.UTC, "Mar 05, 2024"},
+		{"Jan 02, 2006", tokyo, "Mar 06, 2024"},
+		{time.RFC3339, time.UTC, "2024-03-05T22:30:00Z"},
+		{time.RFC3339, tokyo, "2024-03-06T07:30:00+09:00"},
+		{"2006-01-02 15:04", time.UTC, "2024-03-05 22:30"},
+	}
+	for _, tt := range tests {
+		dateFormat, dateLocation = tt.format, tt.loc
+		if got := formatDate(at); got != tt.want {
+			t.Errorf("formatDate in %s with %q = %q, want %q", tt.loc, tt.format, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkFormatFileSize formats the sizes a listing typically shows. Each
+// call makes two allocations, for the arguments of fmt.Sprintf and for the
+// string it returns; building it with strconv.AppendFloat would save one.
+func BenchmarkFormatFileSize(b *testing.B) {
+	sizes := []int64{1, 1 << 10, 3 << 19, 1288490189, 999 << 40}
+	b.ReportAllocs()
+	for i := range b.N {
+		formatFileSize(sizes[i%len(sizes)])
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"testing/fstest"
+	"time"
+)
+
+// inMemoryFS serves files held in memory, so -temp-preview never writes what
+// it reads from stdin to disk.
+type inMemoryFS struct {
+	files fstest.MapFS
+}
+
+// readPayload reads r into an inMemoryFS holding it as a single file named
+// "payload".
+func readPayload(r io.Reader) (inMemoryFS, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return inMemoryFS{}, err
+	}
+	return inMemoryFS{files: fstest.MapFS{
+		"payload": &fstest.MapFile{Data: data, Mode: 0o444, ModTime: time.Now()},
+	}}, nil
+}
+
+func (m inMemoryFS) Open(name string) (fs.File, error) {
+	return m.files.Open(name)
+}
+
+func (m inMemoryFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return m.files.ReadDir(name)
+}
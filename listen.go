@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// parsePortRange parses a range of ports like 9000-9099.
+func parsePortRange(s string) (first, last int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("want a range like 9000-9099")
+	}
+	if first, err = strconv.Atoi(strings.TrimSpace(from)); err != nil {
+		return 0, 0, err
+	}
+	if last, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+		return 0, 0, err
+	}
+	if first < 1 || last > 65535 || first >= last {
+		return 0, 0, fmt.Errorf("want 1 <= start < end <= 65535")
+	}
+	return first, last, nil
+}
+
+// listenRange listens on the first port from first to last that isn't in
+// use and returns its listeners and the port.
+func listenRange(first, last int, ipv6 bool) ([]net.Listener, int, error) {
+	for port := first; ; port++ {
+		listeners, err := listen(port, ipv6)
+		if err == nil {
+			return listeners, port, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) || port == last {
+			return nil, 0, err
+		}
+	}
+}
+
+// listen opens the listeners for port: one for every address the platform
+// hands out by default, or separate IPv4 and IPv6 ones with ipv6 set.
+func listen(port int, ipv6 bool) ([]net.Listener, error) {
+	if !ipv6 {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{ln}, nil
+	}
+
+	if runtime.GOOS == "linux" {
+		log.Printf("Notice: IPv6 sockets on this platform are usually dual-stack already; listening on IPv4 and IPv6 separately")
+	}
+	ln4, err := net.Listen("tcp4", fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	ln6, err := net.Listen("tcp6", fmt.Sprintf("[::]:%d", port))
+	if err != nil {
+		ln4.Close()
+		return nil, err
+	}
+	return []net.Listener{ln4, ln6}, nil
+}
+
+// portInUse explains that port is taken, suggesting the next free port
+// after it when there is one close by.
+func portInUse(port int) string {
+	msg := fmt.Sprintf("Port %d is already in use.", port)
+	for next := port + 1; next <= min(port+100, 65535); next++ {
+		if ln, err := net.Listen("tcp", fmt.Sprintf(":%d", next)); err == nil {
+			ln.Close()
+			return msg + fmt.Sprintf(" Try -port %d or use -port-range to auto-select.", next)
+		}
+	}
+	return msg + " Use -port-range to auto-select a free one."
+}
@@ -0,0 +1,678 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileInfo is one entry of a directory listing.
+type FileInfo struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+	IsAudio bool
+	IsImage bool
+
+	IsArchive bool
+
+	// Preview is the kind of preview the listing can show of the file
+	// without leaving the page; see previewKind.
+	Preview string
+
+	// Tags are the tags set with /_tag, with -tags.
+	Tags []string
+
+	// Extension is the extension of Name, which doesn't include it when
+	// ExtensionHidden is set.
+	Extension       string
+	ExtensionHidden bool
+
+	IsSymlink       bool
+	IsBrokenSymlink bool
+	SymlinkTarget   string
+
+	AudioTitle  string
+	AudioArtist string
+	AudioAlbum  string
+	AudioTrack  string
+}
+
+// TemplateData is what the directory listing template is executed with.
+type TemplateData struct {
+	Path       string
+	ParentPath string
+	Files      []FileInfo
+
+	FileCount int
+	DirCount  int
+	TotalSize int64
+
+	// Truncated is set when -max-listing-files left entries out of Files;
+	// TotalCount is the number of entries there are.
+	Truncated  bool
+	TotalCount int
+
+	// Filters are the patterns of -filter and ?filter= that files have to
+	// match to be in Files.
+	Filters []string
+
+	// Readme is the rendered README of the directory, if it has one, and
+	// ReadmeName its file name.
+	ReadmeName string
+	Readme     template.HTML
+
+	// Prefix is -prefix, which every URL path of the server starts with.
+	Prefix string
+
+	// Nonce goes on the <script> and <style> tags with -csp-mode strict.
+	Nonce string
+}
+
+var funcMap = template.FuncMap{
+	"formatFileSize": formatFileSize,
+	"formatDate":     formatDate,
+}
+
+const defaultListingTemplate = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+<title>Directory listing for {{.Path}}</title>
+<style{{with .Nonce}} nonce="{{.}}"{{end}}>
+#truncated, #filter { padding: 6px 8px; background: #fe9; }
+#summary { position: sticky; bottom: 0; padding: 6px 8px; background: #333; color: #eee; font-size: 14px; }
+#readme { margin: 8px 0; padding: 0 8px; border: 1px solid #ccc; }
+#readme summary { padding: 6px 0; cursor: pointer; font-weight: bold; }
+.tag { padding: 0 6px; border-radius: 8px; background: #def; color: #036; font-size: 12px; text-decoration: none; }
+#files a:focus { outline: 2px solid #06c; outline-offset: 2px; }
+#search { width: 100%; max-width: 400px; padding: 4px 6px; box-sizing: border-box; }
+#search-results { margin: 8px 0; padding-left: 20px; }
+.preview { border: 0; background: none; padding: 0 4px; cursor: pointer; }
+#preview-modal { position: fixed; inset: 0; display: flex; align-items: center; justify-content: center; background: rgba(0, 0, 0, 0.7); }
+#preview-modal[hidden] { display: none; }
+#preview-box { position: relative; max-width: 90vw; max-height: 90vh; overflow: auto; padding: 16px; background: #fff; }
+#preview-box img, #preview-box video { max-width: 85vw; max-height: 80vh; }
+#preview-box pre { margin: 0; white-space: pre-wrap; }
+#preview-close { position: absolute; top: 0; right: 0; border: 0; background: none; font-size: 20px; cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>Directory listing for {{.Path}}</h1>
+<form id="search-form" role="search" action="{{.Prefix}}/_search"><input id="search" type="search" name="q" placeholder="Search all files (/)" aria-label="Search all files"></form>
+<ul id="search-results" hidden></ul>
+<hr>
+{{- if .Filters}}
+<p id="filter">Showing only: {{range $i, $f := .Filters}}{{if $i}}, {{end}}{{$f}}{{end}}.</p>
+{{- end}}
+{{- if .Truncated}}
+<p id="truncated">Showing first {{len .Files}} files of {{.TotalCount}} total.</p>
+{{- end}}
+{{- if .Readme}}
+<details id="readme" open><summary>{{.ReadmeName}}</summary>
+{{.Readme}}
+</details>
+{{- end}}
+<table id="files">
+{{- if .ParentPath}}
+<tr><td><a id="parent" href="{{.ParentPath}}">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Files}}
+<tr{{if .IsDir}} class="dir"{{end}}><td>{{if .IsBrokenSymlink}}⚠️ {{else if .IsSymlink}}🔗 {{end}}<a href="{{.Path}}" title="{{.Name}}{{if .ExtensionHidden}}{{.Extension}}{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a>{{with .Preview}} <button class="preview" data-kind="{{.}}" title="Preview" aria-label="Preview">👁</button>{{end}}{{if .IsArchive}} <small><a href="{{.Path}}/">browse</a></small>{{end}}{{range .Tags}} <a class="tag" href="?tag={{.}}">{{.}}</a>{{end}}{{if .IsBrokenSymlink}} (broken link){{else if .IsSymlink}} → {{.SymlinkTarget}}{{end}}{{if .AudioTitle}} <small>{{.AudioTitle}}{{if .AudioArtist}} · {{.AudioArtist}}{{end}}</small>{{end}}</td><td>{{if not (or .IsDir .IsBrokenSymlink)}}{{formatFileSize .Size}}{{end}}</td><td>{{formatDate .ModTime}}</td></tr>
+{{- end}}
+</table>
+<hr>
+<div id="preview-modal" hidden><div id="preview-box"><button id="preview-close" aria-label="Close">×</button><div id="preview-content"></div></div></div>
+<footer id="summary">{{.FileCount}} file{{if ne .FileCount 1}}s{{end}}, {{.DirCount}} director{{if eq .DirCount 1}}y{{else}}ies{{end}} — Total size: {{formatFileSize .TotalSize}}</footer>
+<script{{with .Nonce}} nonce="{{.}}"{{end}}>
+// refresh the table in place whenever the directory changes, over a
+// WebSocket when possible and server-sent events otherwise
+(function () {
+  if (!window.fetch) return;
+  var prefix = {{.Prefix}}, dir = location.pathname.slice(prefix.length);
+  var pending;
+  function changed() {
+    clearTimeout(pending);
+    pending = setTimeout(refresh, 100);
+  }
+  function refresh() {
+    fetch(location.href, {headers: {Accept: "text/html"}}).then(function (res) { return res.text(); }).then(function (html) {
+      var doc = new DOMParser().parseFromString(html, "text/html");
+      ["files", "summary"].forEach(function (id) {
+        var fresh = doc.getElementById(id), current = document.getElementById(id);
+        if (fresh && current) current.innerHTML = fresh.innerHTML;
+      });
+    });
+  }
+  function useEvents() {
+    if (window.EventSource) new EventSource(prefix + "/_events" + dir).onmessage = changed;
+  }
+  if (!window.WebSocket) return useEvents();
+  var proto = location.protocol === "https:" ? "wss:" : "ws:";
+  // a socket that was open reconnects when it drops, refreshing for what it
+  // missed; one that never opened falls back to server-sent events
+  function connect(reconnecting) {
+    var ws = new WebSocket(proto + "//" + location.host + prefix + "/_ws" + dir);
+    var opened = false;
+    ws.onopen = function () {
+      opened = true;
+      if (reconnecting) changed();
+    };
+    ws.onmessage = changed;
+    ws.onclose = function () {
+      if (opened) setTimeout(function () { connect(true); }, 2000);
+      else if (reconnecting) setTimeout(function () { connect(true); }, 10000);
+      else useEvents();
+    };
+  }
+  connect(false);
+})();
+
+// the eye buttons show the file in a modal: images, audio and video as
+// they are, text files as their first 100 lines
+(function () {
+  var modal = document.getElementById("preview-modal");
+  var content = document.getElementById("preview-content");
+  function close() {
+    modal.hidden = true;
+    content.innerHTML = "";
+  }
+  function open(kind, url) {
+    var el;
+    if (kind === "image") {
+      el = document.createElement("img");
+    } else if (kind === "audio" || kind === "video") {
+      el = document.createElement(kind);
+      el.controls = true;
+      el.autoplay = true;
+    } else {
+      el = document.createElement("pre");
+      el.textContent = "Loading…";
+      fetch(url, {headers: {Range: "bytes=0-262143"}}).then(function (res) { return res.text(); }).then(function (text) {
+        el.textContent = text.split("\n").slice(0, 100).join("\n");
+      }, function () { el.textContent = "Could not load the file."; });
+    }
+    if (kind !== "text") el.src = url;
+    content.innerHTML = "";
+    content.appendChild(el);
+    modal.hidden = false;
+  }
+  // the table is replaced when the directory changes, so listen on the document
+  document.addEventListener("click", function (e) {
+    var button = e.target.closest && e.target.closest(".preview");
+    if (button) {
+      open(button.dataset.kind, button.previousElementSibling.href);
+    } else if (e.target === modal || e.target.id === "preview-close") {
+      close();
+    }
+  });
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "Escape" && !modal.hidden) close();
+  });
+})();
+
+// typing in the search box lists the first matches from /_search below it
+(function () {
+  var input = document.getElementById("search"), list = document.getElementById("search-results");
+  if (!input || !window.fetch) return;
+  var prefix = {{.Prefix}}, pending, current;
+  function show(results) {
+    list.innerHTML = "";
+    results.slice(0, 50).forEach(function (r) {
+      var li = document.createElement("li"), a = document.createElement("a");
+      a.href = prefix + r.path.split("/").map(encodeURIComponent).join("/");
+      a.textContent = r.path;
+      li.appendChild(a);
+      list.appendChild(li);
+    });
+    if (!results.length) list.innerHTML = "<li>No matches.</li>";
+    list.hidden = false;
+  }
+  input.addEventListener("input", function () {
+    clearTimeout(pending);
+    var q = input.value.trim();
+    current = q;
+    if (!q) {
+      list.hidden = true;
+      return;
+    }
+    pending = setTimeout(function () {
+      fetch(prefix + "/_search?q=" + encodeURIComponent(q)).then(function (res) { return res.ok ? res.json() : []; }).then(function (results) {
+        if (current === q) show(results);
+      });
+    }, 200);
+  });
+  input.form.addEventListener("submit", function (e) { e.preventDefault(); });
+  input.addEventListener("keydown", function (e) {
+    if (e.key !== "Escape") return;
+    input.value = current = "";
+    list.hidden = true;
+    input.blur();
+  });
+})();
+
+// j/k or the arrow keys move between entries, Enter or l opens one,
+// h or the left arrow goes to the parent directory and / focuses search
+document.addEventListener("keydown", function (e) {
+  var target = e.target.tagName;
+  if (!document.getElementById("preview-modal").hidden) return;
+  if (e.ctrlKey || e.metaKey || e.altKey || target === "INPUT" || target === "TEXTAREA" || target === "SELECT") return;
+  var links = Array.prototype.slice.call(document.querySelectorAll("#files tr > td:first-child > a"));
+  var i = links.indexOf(document.activeElement);
+  switch (e.key) {
+  case "j": case "ArrowDown": i = Math.min(i + 1, links.length - 1); break;
+  case "k": case "ArrowUp": i = Math.max(i - 1, 0); break;
+  case "l": case "Enter": if (i >= 0) location.href = links[i].href; return;
+  case "h": case "ArrowLeft":
+    var parent = document.getElementById("parent");
+    if (parent) location.href = parent.href;
+    return;
+  case "/":
+    var search = document.getElementById("search");
+    if (!search) return;
+    e.preventDefault();
+    search.focus();
+    return;
+  default: return;
+  }
+  e.preventDefault();
+  if (links[i]) links[i].focus();
+});
+</script>
+</body>
+</html>
+`
+
+// listingTemplate holds the parsed directory listing template. When it was
+// loaded from a file and reload is set, the file is parsed again whenever its
+// modification time changes.
+type listingTemplate struct {
+	file   string
+	reload bool
+
+	mu      sync.Mutex
+	modTime time.Time
+	tmpl    *template.Template
+}
+
+func newListingTemplate() *listingTemplate {
+	return &listingTemplate{
+		tmpl: template.Must(template.New("listing").Funcs(funcMap).Parse(defaultListingTemplate)),
+	}
+}
+
+func loadListingTemplate(file string, reload bool) (*listingTemplate, error) {
+	lt := &listingTemplate{file: file, reload: reload}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := lt.parse(info.ModTime()); err != nil {
+		return nil, err
+	}
+	return lt, nil
+}
+
+func (lt *listingTemplate) parse(modTime time.Time) error {
+	tmpl, err := template.New(filepath.Base(lt.file)).Funcs(funcMap).ParseFiles(lt.file)
+	if err != nil {
+		return err
+	}
+	lt.tmpl = tmpl
+	lt.modTime = modTime
+	return nil
+}
+
+func (lt *listingTemplate) get() *template.Template {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if lt.file == "" || !lt.reload {
+		return lt.tmpl
+	}
+	info, err := os.Stat(lt.file)
+	if err != nil || info.ModTime().Equal(lt.modTime) {
+		return lt.tmpl
+	}
+	if err := lt.parse(info.ModTime()); err != nil {
+		log.Printf("Could not reload template %s: %v", lt.file, err)
+	} else {
+		log.Printf("Reloaded template %s", lt.file)
+	}
+	return lt.tmpl
+}
+
+// wantsTextListing reports whether r should get the plain text listing:
+// with ?format=text, or from clients like curl that don't ask for HTML.
+func wantsTextListing(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "text" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") || !strings.Contains(accept, "text/html")
+}
+
+// textListing lists files one per line, as "D name/" for directories and
+// "F name size" for everything else.
+func textListing(files []FileInfo) []byte {
+	var buf bytes.Buffer
+	for _, f := range files {
+		if f.IsDir {
+			fmt.Fprintf(&buf, "D %s/\n", f.Name)
+		} else {
+			fmt.Fprintf(&buf, "F %s %d\n", f.Name, f.Size)
+		}
+	}
+	return buf.Bytes()
+}
+
+func (s *server) renderDirectoryListing(w http.ResponseWriter, r *http.Request, dirPath string, dir http.File) {
+	text := wantsTextListing(r)
+	contentType := "text/html; charset=utf-8"
+	cacheKey := dirPath + "?" + r.URL.RawQuery
+	if text {
+		contentType = "text/plain; charset=utf-8"
+		cacheKey += "#text"
+	}
+	w.Header().Set("Vary", "Accept")
+
+	etag := ""
+	if s.listingCache != nil {
+		if info, err := dir.Stat(); err == nil {
+			etag = listingETag(info)
+			if body, ok := s.listingCache.get(cacheKey, etag); ok {
+				// strict mode, whose nonce changes every time, isn't cached
+				if !text {
+					s.setCSP(w)
+				}
+				w.Header().Set("Content-Type", contentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				w.Write(body)
+				return
+			}
+		}
+	}
+	// a HEAD request gets the headers without the directory being read and
+	// rendered; only a cached listing above knows its length
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", contentType)
+		return
+	}
+
+	global, request, err := s.listingFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	files, err := s.listFiles(dirPath, dir)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	files = hideChapterFiles(files)
+	if s.tags {
+		files = s.addTags(dirPath, files)
+	}
+	var readmeName string
+	var readme template.HTML
+	if !text && !s.noReadme {
+		readmeName, readme = s.readme(dirPath, files)
+	}
+	files = filterFiles(files, global, request)
+	filters := append(append([]string{}, global...), request...)
+	if tag := r.URL.Query().Get("tag"); tag != "" && s.tags {
+		files = filterTagged(files, tag)
+		filters = append(filters, "tagged "+tag)
+	}
+	data := s.listingData(dirPath, files)
+	data.Filters = filters
+	data.ReadmeName, data.Readme = readmeName, readme
+	if text {
+		if data.Truncated {
+			w.Header().Set("X-Total-Count", strconv.Itoa(data.TotalCount))
+		}
+		body := textListing(data.Files)
+		if etag != "" {
+			s.listingCache.put(cacheKey, etag, body)
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+		return
+	}
+
+	s.hideFileExtensions(data.Files)
+	data.Nonce = s.setCSP(w)
+	var buf bytes.Buffer
+	if err := s.listing.get().Execute(&buf, data); err != nil {
+		log.Printf("Could not render listing for %s: %v", dirPath, err)
+		http.Error(w, "Error rendering directory listing", http.StatusInternalServerError)
+		return
+	}
+	if etag != "" {
+		s.listingCache.put(cacheKey, etag, buf.Bytes())
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// listingData is what the listing template gets for files, the sorted
+// entries of the directory at URL path dirPath.
+func (s *server) listingData(dirPath string, files []FileInfo) TemplateData {
+	data := TemplateData{
+		Prefix:     s.urlPrefix,
+		Path:       dirPath,
+		Files:      files,
+		TotalCount: len(files),
+	}
+	if s.maxListingFiles > 0 && len(files) > s.maxListingFiles {
+		data.Files = files[:s.maxListingFiles]
+		data.Truncated = true
+	}
+	for _, f := range files {
+		if f.IsDir {
+			data.DirCount++
+			continue
+		}
+		data.FileCount++
+		if !f.IsBrokenSymlink {
+			data.TotalSize += f.Size
+		}
+	}
+	if dirPath != "/" {
+		data.Path += "/"
+		parent := path.Dir(dirPath)
+		if parent != "/" {
+			parent += "/"
+		}
+		data.ParentPath = s.urlPath(parent)
+	}
+	return data
+}
+
+// listFiles reads the entries of dir, which lives at URL path dirPath, and
+// returns them sorted: directories first, then by name, broken symlinks last.
+func (s *server) listFiles(dirPath string, dir http.File) ([]FileInfo, error) {
+	entries, err := readDirEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// stat'ing entries one after the other is slow on high latency file
+	// systems such as NFS, so hand them out to a bounded set of workers
+	results := make([]FileInfo, len(entries))
+	found := make([]bool, len(entries))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i], found[i] = s.fileInfo(dirPath, entries[i])
+			}
+		}()
+	}
+	for i := range entries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	files := make([]FileInfo, 0, len(entries))
+	for i, fi := range results {
+		if found[i] {
+			files = append(files, fi)
+		}
+	}
+	sortFiles(files)
+	return files, nil
+}
+
+// hideFileExtensions strips the extension from the names of files with
+// -hide-extensions, except for the extensions of -show-extension-for.
+func (s *server) hideFileExtensions(files []FileInfo) {
+	if !s.hideExtensions {
+		return
+	}
+	for i, f := range files {
+		// a dotfile like .bashrc is all extension
+		if f.Extension == "" || f.Extension == f.Name || s.shownExtensions[strings.ToLower(f.Extension)] {
+			continue
+		}
+		files[i].Name = strings.TrimSuffix(f.Name, f.Extension)
+		files[i].ExtensionHidden = true
+	}
+}
+
+// sortFiles puts directories first, then sorts by name, broken symlinks last.
+func sortFiles(files []FileInfo) {
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].IsBrokenSymlink != files[j].IsBrokenSymlink {
+			return files[j].IsBrokenSymlink
+		}
+		if files[i].IsDir != files[j].IsDir {
+			return files[i].IsDir
+		}
+		return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
+	})
+}
+
+// readDirEntries lists dir, leaving the stat of each entry for later when
+// the file system supports it.
+func readDirEntries(dir http.File) ([]fs.DirEntry, error) {
+	if rd, ok := dir.(fs.ReadDirFile); ok {
+		return rd.ReadDir(-1)
+	}
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+// fileInfo builds the listing entry for entry in dirPath. It reports false
+// if the entry vanished before it could be stat'ed.
+func (s *server) fileInfo(dirPath string, entry fs.DirEntry) (FileInfo, bool) {
+	info, err := entry.Info()
+	if err != nil {
+		return FileInfo{}, false
+	}
+	p := path.Join(dirPath, entry.Name())
+	fi := FileInfo{Name: entry.Name()}
+
+	// entries come from Lstat, so symlinks have to be followed by hand
+	if info.Mode()&os.ModeSymlink != 0 {
+		fi.IsSymlink = true
+		target, targetInfo, err := s.resolveSymlink(p)
+		if err != nil {
+			fi.IsBrokenSymlink = true
+		} else {
+			fi.SymlinkTarget = target
+			info = targetInfo
+		}
+	}
+
+	if info.IsDir() {
+		p += "/"
+	}
+	fi.Path = s.urlPath(p)
+	fi.Size = info.Size()
+	fi.ModTime = info.ModTime()
+	fi.IsDir = info.IsDir()
+	fi.IsAudio = !fi.IsDir && !fi.IsBrokenSymlink && isAudioFile(fi.Name)
+	fi.IsImage = !fi.IsDir && !fi.IsBrokenSymlink && isImageFile(fi.Name)
+	fi.IsArchive = !fi.IsDir && !fi.IsBrokenSymlink && isTarArchive(fi.Name)
+	if !fi.IsDir && !fi.IsBrokenSymlink {
+		fi.Preview = previewKind(fi.Name)
+	}
+	if !fi.IsDir {
+		fi.Extension = path.Ext(fi.Name)
+	}
+	if fi.IsAudio && s.audioMeta {
+		if meta, err := s.readAudioMeta(p, false); err == nil {
+			fi.AudioTitle = meta.Title
+			fi.AudioArtist = meta.Artist
+			fi.AudioAlbum = meta.Album
+			fi.AudioTrack = meta.Track
+		}
+	}
+	return fi, true
+}
+
+// sizeFormat is how formatFileSize shows sizes, set from -size-format: iec
+// for 1024-based KiB, MiB, ..., si for 1000-based KB, MB, ... and bytes for
+// the plain byte count.
+var sizeFormat = "iec"
+
+func formatFileSize(size int64) string {
+	unit, suffix := int64(1024), "iB"
+	switch sizeFormat {
+	case "bytes":
+		return fmt.Sprintf("%d B", size)
+	case "si":
+		unit, suffix = 1000, "B"
+	}
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := unit, 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	// just below the next unit would round up to 1024.0 KiB or 1000.0 KB
+	if exp < 5 && float64(size)/float64(div) >= float64(unit)-0.05 {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %c%s", float64(size)/float64(div), "KMGTPE"[exp], suffix)
+}
+
+// dateFormat and dateLocation are how formatDate shows times, set from
+// -date-format, -timezone and -utc.
+var (
+	dateFormat   = "Jan 02, 2006"
+	dateLocation = time.Local
+)
+
+func formatDate(t time.Time) string {
+	return t.In(dateLocation).Format(dateFormat)
+}
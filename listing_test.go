@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// BenchmarkRenderListing renders the listing of a directory large enough
+// for the stat workers to matter.
+func BenchmarkRenderListing(b *testing.B) {
+	dir := b.TempDir()
+	for i := range 3000 {
+		if err := os.WriteFile(filepath.Join(dir, "file"+strconv.Itoa(i)+".txt"), nil, 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	s := newServer(dir, http.Dir(dir), newListingTemplate(), false)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		w := httptest.NewRecorder()
+		s.handleRequest(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status %d", w.Code)
+		}
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	defer func(f string) { sizeFormat = f }(sizeFormat)
+	tests := []struct {
+		format string
+		size   int64
+		want   string
+	}{
+		{"iec", 0, "0 B"},
+		{"iec", 1023, "1023 B"},
+		{"iec", 1024, "1.0 KiB"},
+		{"iec", 1536, "1.5 KiB"},
+		{"iec", 1024*1024 - 1, "1.0 MiB"},
+		{"iec", 1024 * 1024, "1.0 MiB"},
+		{"iec", 1 << 40, "1.0 TiB"},
+		{"si", 999, "999 B"},
+		{"si", 1000, "1.0 KB"},
+		{"si", 1023, "1.0 KB"},
+		{"si", 1024, "1.0 KB"},
+		{"si", 999949, "999.9 KB"},
+		{"si", 999999, "1.0 MB"},
+		{"si", 1000000, "1.0 MB"},
+		{"si", 1e12, "1.0 TB"},
+		{"bytes", 999, "999 B"},
+		{"bytes", 1000, "1000 B"},
+		{"bytes", 1023, "1023 B"},
+		{"bytes", 1024, "1024 B"},
+		{"bytes", 1 << 40, "1099511627776 B"},
+	}
+	for _, tt := range tests {
+		sizeFormat = tt.format
+		if got := formatFileSize(tt.size); got != tt.want {
+			t.Errorf("%s formatFileSize(%d) = %q, want %q", tt.format, tt.size, got, tt.want)
+		}
+	}
+}
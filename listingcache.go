@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type cachedListing struct {
+	html   []byte
+	etag   string
+	expiry time.Time
+}
+
+// listingCache keeps rendered directory listings for a short while so
+// repeated requests don't read the directory and run the template again.
+// Entries are only reused while the directory's etag, derived from its
+// modification time, stays the same.
+type listingCache struct {
+	ttl     time.Duration
+	entries sync.Map
+
+	hits, misses atomic.Int64
+}
+
+func newListingCache(ttl time.Duration) *listingCache {
+	c := &listingCache{ttl: ttl}
+	go c.prune()
+	return c
+}
+
+func listingETag(info fs.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+func (c *listingCache) get(key, etag string) ([]byte, bool) {
+	v, ok := c.entries.Load(key)
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := v.(cachedListing)
+	if entry.etag != etag || time.Now().After(entry.expiry) {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return entry.html, true
+}
+
+func (c *listingCache) put(key, etag string, html []byte) {
+	c.entries.Store(key, cachedListing{html: html, etag: etag, expiry: time.Now().Add(c.ttl)})
+}
+
+// invalidate drops every cached listing of dirPath.
+func (c *listingCache) invalidate(dirPath string) {
+	prefix := dirPath + "?"
+	c.entries.Range(func(key, _ any) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			c.entries.Delete(key)
+		}
+		return true
+	})
+}
+
+// clear drops every cached listing.
+func (c *listingCache) clear() {
+	c.entries.Range(func(key, _ any) bool {
+		c.entries.Delete(key)
+		return true
+	})
+}
+
+func (c *listingCache) prune() {
+	ticker := time.NewTicker(c.ttl)
+	for now := range ticker.C {
+		c.entries.Range(func(key, v any) bool {
+			if now.After(v.(cachedListing).expiry) {
+				c.entries.Delete(key)
+			}
+			return true
+		})
+	}
+}
@@ -1,39 +1,647 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"os"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
-)
+	"strings"
+	"syscall"
+	"time"
 
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
 
 func main() {
 	// directory nya
 	dir := flag.String("dir", ".", "the directory of static file to host")
-	flag.Parse()
 
 	// port nya
 	port := flag.Int("port", 9000, "port to serve on")
+	portRange := flag.String("port-range", "", "serve on the first free port of a range like 9000-9099, instead of -port")
+
+	// gitignore nya
+	gitignore := flag.Bool("gitignore", false, "hide files matched by .gitignore rules from directory listings")
+
+	// template nya
+	templateFile := flag.String("template", "", "template file to render directory listings with")
+
+	// audio metadata nya
+	audioMeta := flag.Bool("audio-meta", false, "show audio tags in directory listings and serve cover art at /_cover/")
+
+	// listing cache nya
+	listingCacheTTL := flag.Int("listing-cache-ttl", 2, "seconds to cache rendered directory listings, 0 to disable (always off with -verbose and -csp-mode strict)")
+
+	// listing limit nya
+	maxListingFiles := flag.Int("max-listing-files", 0, "show at most this many entries in a directory listing, 0 for no limit")
+
+	// pdf viewer nya
+	noPDFViewer := flag.Bool("no-pdf-viewer", false, "serve PDFs as plain files instead of in a viewer page")
+
+	// robots nya
+	allowRobots := flag.Bool("allow-robots", false, "let crawlers index the server when the directory has no robots.txt")
+
+	// content type nya
+	allowContentTypeOverride := flag.Bool("allow-content-type-override", false, "let ?content_type= choose the Content-Type a file is served with")
+
+	// feed nya
+	feedTitle := flag.String("feed-title", "", "title of the RSS feed at /_feed (default the directory name)")
+
+	// sitemap nya
+	sitemapExclude := flag.String("sitemap-exclude", "", "comma separated patterns of files to leave out of /sitemap.xml, like *.log,/drafts/*")
+
+	// index file nya
+	indexFile := flag.String("index-file", "index.html", "file to serve instead of a listing when a directory has it")
+	noIndexFile := flag.Bool("no-index-file", false, "always show directory listings, even when the index file exists")
+
+	// spa nya
+	spa := flag.Bool("spa", false, "serve /index.html for missing paths so single-page apps can route them (implies -index-file index.html)")
+	spaPassthroughExts := flag.String("spa-passthrough-exts", "", "comma separated extensions that still get a 404 when missing with -spa, like .js,.css (default any extension)")
+
+	// listing nya
+	disableListing := flag.Bool("disable-listing", false, "answer directory requests with 403 instead of a listing; files stay reachable by URL")
+	maxDirDepth := flag.Int("max-dir-depth", -1, "answer 403 for directories deeper than this below the root, and the files in them; 0 serves only the root's files, -1 is unlimited")
+	disableListingPath := flag.String("disable-listing-path", "", "comma separated directories, like /secret, whose subtrees get 403 instead of listings")
+
+	// overlay nya
+	overlay := flag.String("overlay", "", "comma separated directories layered on top of -dir, first one wins when a path exists in several")
+
+	// content disposition nya
+	contentDisposition := flag.String("content-disposition", "", "set to attachment to make browsers download files instead of showing them")
+	attachmentExts := flag.String("attachment-exts", "", "comma separated extensions -content-disposition applies to, like .pdf,.doc (default all files)")
+
+	// size nya
+	sizeFormatFlag := flag.String("size-format", "iec", "how listings show file sizes: iec (1024-based KiB), si (1000-based KB) or bytes")
+	si := flag.Bool("si", false, "shorthand for -size-format si, like ls --si")
+
+	// date nya
+	dateFormatFlag := flag.String("date-format", "Jan 02, 2006", "Go time layout for dates in directory listings")
+	timezone := flag.String("timezone", "", "time zone for dates in directory listings, like America/New_York (default the system time zone)")
+	utc := flag.Bool("utc", false, "show dates in directory listings in UTC, overriding -timezone")
+
+	// extension nya
+	hideExtensions := flag.Bool("hide-extensions", false, "show file names without their extension in directory listings")
+	showExtensionFor := flag.String("show-extension-for", "", "comma separated extensions -hide-extensions leaves alone, like .zip,.pdf")
+
+	// prefix nya
+	prefix := flag.String("prefix", "", "serve below this path, like /files, for a reverse proxy that maps it to the server root")
+
+	// proxy nya
+	proxyOrigin := flag.String("proxy-origin", "", "fetch files that don't exist locally from this origin, like https://cdn.example.com")
+
+	// blocklist nya
+	blocklistExts := flag.String("blocklist-exts", "", "comma separated extensions never to serve or list, like .env,.key,.pem,.sqlite")
+
+	// symlink nya
+	followSymlinks := flag.Bool("follow-symlinks", false, "allow access to symlink targets outside the served directory")
+
+	// timeout nya
+	readTimeout := flag.Int("read-timeout", 60, "seconds allowed to read a whole request, 0 for no timeout")
+	writeTimeout := flag.Int("write-timeout", 120, "seconds allowed to write a response, 0 for no timeout")
+	idleTimeout := flag.Int("idle-timeout", 120, "seconds to keep idle keep-alive connections open, 0 for no timeout")
+	headerTimeout := flag.Int("header-timeout", 10, "seconds allowed to read request headers, 0 for no timeout")
+
+	// throttle nya
+	throttleKBps := flag.Int("throttle-kbps", 0, "limit each file download or listing to this many KiB per second, 0 for no limit")
+
+	// open nya
+	openFlag := flag.Bool("open", false, "open the server in the default browser once it is listening")
+
+	// ipv6 nya
+	ipv6 := flag.Bool("ipv6", false, "listen on IPv4 and IPv6 with separate sockets")
+	cspMode := flag.String("csp-mode", "off", "Content-Security-Policy for the listing, viewer and player pages: strict (per-request nonce), permissive ('unsafe-inline') or off")
+	webdavFlag := flag.Bool("webdav", false, "serve the directory read only over WebDAV, to mount it as a network drive")
+	webdavPrefix := flag.String("webdav-prefix", "/_dav", "path the WebDAV share is served at with -webdav")
+	h2cFlag := flag.Bool("h2c", false, "accept HTTP/2 without TLS (h2c), for use behind a TLS-terminating reverse proxy")
+
+	// tags nya
+	tagsFlag := flag.Bool("tags", false, "show tags set with POST /_tag (needs -admin-token) in listings, and filter them with ?tag=")
+
+	// readme nya
+	noReadme := flag.Bool("no-readme", false, "don't show a directory's README above its listing")
+
+	// filter nya
+	var filters filterFlag
+	flag.Var(&filters, "filter", "only list files matching this glob pattern, like *.pdf; directories are always listed (repeatable)")
+
+	// header nya
+	headers := headerFlag{}
+	flag.Var(headers, "header", "add a \"Name: Value\" header to every response that doesn't set it already (repeatable)")
+
+	// shutdown message nya
+	shutdownMessage := flag.String("shutdown-message", "", "answer requests that arrive while shutting down with 503 and this message")
+
+	// path rate limit nya
+	var pathLimits pathRateFlag
+	flag.Var(&pathLimits, "path-rate-limit", "limit requests below a path prefix to this many per second across all clients, like /uploads=5 (repeatable)")
+
+	// request id nya
+	trustRequestID := flag.Bool("trust-request-id", false, "keep the X-Request-ID sent by clients instead of always generating one")
+	logUserAgent := flag.Bool("log-user-agent", false, "write an access log line with the User-Agent for every request, also without -verbose")
+	logSlow := flag.Duration("log-slow-requests", 0, "log requests that take at least this long, like 500ms, as warnings, also without -verbose")
+
+	// audit nya
+	auditLogFile := flag.String("audit-log", "", "append a JSON line to this file for every tag or share link change (must be outside the served directory)")
+
+	// share nya
+	shareFile := flag.String("share-file", "", "JSON file to keep share links from /_share in across restarts, outside the served directory (default in memory only)")
+
+	// signed url nya
+	secretKey := flag.String("secret-key", "", "secret (at least 32 bytes) for signing download URLs at /_sign")
+
+	// download stats nya
+	statsFile := flag.String("stats-file", "", "count downloads per file and keep the counts in this JSON file, outside the served directory")
+
+	// max connections nya
+	maxConnections := flag.Int("max-connections", 0, "handle at most this many requests at once and answer the rest with 503, 0 for no limit")
+
+	// admin nya
+	adminToken := flag.String("admin-token", "", "bearer token (at least 32 characters) that enables the /_admin/ endpoints")
+
+	// pid file nya
+	pidFile := flag.String("pid-file", "", "write the process ID to this file while running")
+	force := flag.Bool("force", false, "start even if the PID file belongs to a running process")
+
+	// quiet nya
+	quiet := flag.Bool("quiet", false, "don't print the startup banner")
+	// temp-preview nya
+	tempPreview := flag.Bool("temp-preview", false, "serve stdin from memory as a single file named payload instead of -dir")
+
+	// verbose nya
+	verbose := flag.Bool("verbose", false, "log more details and reload the listing template when it changes")
+
+	// env file nya
+	envFile := flag.String("env-file", "", "load SIMPLE_HTTP_* settings from this .env file; the environment and command line win over it")
 	flag.Parse()
 
+	if *envFile != "" {
+		if err := loadEnvFile(*envFile); err != nil {
+			log.Fatalf("Could not load env file: %v", err)
+		}
+	}
+	applyEnvFlags()
+
+	if *adminToken != "" && len(*adminToken) < minAdminTokenLength {
+		log.Fatalf("The admin token must be at least %d characters long", minAdminTokenLength)
+	}
+
+	if *secretKey != "" && len(*secretKey) < minSecretKeyLength {
+		log.Fatalf("The secret key must be at least %d bytes long", minSecretKeyLength)
+	}
+
+	if *contentDisposition != "" && *contentDisposition != "attachment" {
+		log.Fatalf("Unsupported -content-disposition %q, only attachment is supported", *contentDisposition)
+	}
+
+	switch *sizeFormatFlag {
+	case "iec", "si", "bytes":
+		sizeFormat = *sizeFormatFlag
+	default:
+		log.Fatalf("Unknown -size-format %q, use iec, si or bytes", *sizeFormatFlag)
+	}
+	if *si {
+		sizeFormat = "si"
+	}
+	dateFormat = *dateFormatFlag
+	if *utc {
+		dateLocation = time.UTC
+	} else if *timezone != "" {
+		loc, err := time.LoadLocation(*timezone)
+		if err != nil {
+			log.Fatalf("Could not load time zone %s: %v", *timezone, err)
+		}
+		dateLocation = loc
+	}
+
+	if *prefix != "" && (!strings.HasPrefix(*prefix, "/") || strings.HasSuffix(*prefix, "/")) {
+		log.Fatalf("Invalid -prefix %s, it must start with / and not end with it", *prefix)
+	}
+
+	switch *cspMode {
+	case "strict", "permissive", "off":
+	default:
+		log.Fatalf("Unknown -csp-mode %q, use strict, permissive or off", *cspMode)
+	}
+
+	var firstPort, lastPort int
+	if *portRange != "" {
+		var err error
+		if firstPort, lastPort, err = parsePortRange(*portRange); err != nil {
+			log.Fatalf("Invalid -port-range %s: %v", *portRange, err)
+		}
+	}
+
+	if *proxyOrigin != "" {
+		if u, err := url.Parse(*proxyOrigin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("Invalid -proxy-origin %s, want an http or https URL", *proxyOrigin)
+		}
+	}
+
 	// working directory
 	absDir, err := filepath.Abs(*dir)
 	if err != nil {
 		log.Fatalf("Could not determine the absolute path of directory %s", *dir)
 	}
 
+	// file system
+	layerDirs := []string{absDir}
+	if *overlay != "" {
+		layerDirs = nil
+		for _, d := range strings.Split(*overlay, ",") {
+			layer, err := filepath.Abs(d)
+			if err != nil {
+				log.Fatalf("Could not determine the absolute path of directory %s", d)
+			}
+			layerDirs = append(layerDirs, layer)
+		}
+		layerDirs = append(layerDirs, absDir)
+	}
+	var layers []http.FileSystem
+	for _, d := range layerDirs {
+		var layer http.FileSystem = http.Dir(d)
+		if !*followSymlinks {
+			layer = symlinkFS{FileSystem: layer, root: realPath(d)}
+		}
+		layers = append(layers, layer)
+	}
+	root := layers[0]
+	if len(layers) > 1 {
+		root = overlayFS{layers: layers}
+	}
+	var payloadSize int
+	if *tempPreview {
+		payload, err := readPayload(os.Stdin)
+		if err != nil {
+			log.Fatalf("Could not read stdin: %v", err)
+		}
+		payloadSize = len(payload.files["payload"].Data)
+		root = http.FS(payload)
+	}
+	if *gitignore {
+		root = gitignoreFS{FileSystem: root, roots: layerDirs}
+	}
+	blocked := map[string]bool{}
+	if *blocklistExts != "" {
+		for _, ext := range strings.Split(*blocklistExts, ",") {
+			blocked[strings.ToLower(strings.TrimSpace(ext))] = true
+		}
+		root = blocklistFS{FileSystem: root, exts: blocked}
+	}
+	if *maxDirDepth >= 0 {
+		root = depthFS{FileSystem: root, max: *maxDirDepth}
+	}
+	for _, d := range layerDirs {
+		if !*tempPreview {
+			warnUnblocked(d, blocked)
+		}
+	}
+
+	// pid file
+	if *pidFile != "" {
+		file := os.ExpandEnv(*pidFile)
+		if err := writePIDFile(file, *force); err != nil {
+			log.Fatalf("Could not write PID file: %v", err)
+		}
+		defer os.Remove(file)
+	}
+
+	// listing template
+	listing := newListingTemplate()
+	if *templateFile != "" {
+		listing, err = loadListingTemplate(*templateFile, *verbose)
+		if err != nil {
+			log.Fatalf("Could not load template %s: %v", *templateFile, err)
+		}
+	}
+
 	// file server handler
-	fileServer := http.FileServer(http.Dir(absDir))
-	http.Handle("/", fileServer)
+	srv := newServer(absDir, root, listing, *verbose)
+	srv.layerDirs = layerDirs
+	srv.audioMeta = *audioMeta
+	srv.noPDFViewer = *noPDFViewer
+	srv.throttleKBps = *throttleKBps
+	srv.maxListingFiles = *maxListingFiles
+	srv.proxyOrigin = *proxyOrigin
+	if *hideExtensions {
+		srv.hideExtensions = true
+		srv.shownExtensions = map[string]bool{}
+		for _, ext := range strings.Split(*showExtensionFor, ",") {
+			srv.shownExtensions[strings.ToLower(strings.TrimSpace(ext))] = true
+		}
+	}
+	srv.allowRobots = *allowRobots
+	srv.allowContentTypeOverride = *allowContentTypeOverride
+	srv.feedTitle = *feedTitle
+	if !*noIndexFile {
+		srv.indexFile = *indexFile
+	}
+	srv.disableListing = *disableListing
+	srv.blocklist = blocklistFS{exts: blocked}
+	srv.maxDirDepth = *maxDirDepth
+	if *statsFile != "" {
+		file, err := filepath.Abs(*statsFile)
+		if err != nil {
+			log.Fatalf("Invalid -stats-file %s: %v", *statsFile, err)
+		}
+		if err := checkOutside(file, layerDirs); err != nil {
+			log.Fatalf("Invalid -stats-file: %v", err)
+		}
+		if srv.downloads, err = loadDownloadCounter(file); err != nil {
+			log.Fatalf("Could not load download stats from %s: %v", *statsFile, err)
+		}
+	}
+	srv.forceAttachment = *contentDisposition == "attachment"
+	if *attachmentExts != "" {
+		srv.attachmentExts = map[string]bool{}
+		for _, ext := range strings.Split(*attachmentExts, ",") {
+			srv.attachmentExts[strings.ToLower(strings.TrimSpace(ext))] = true
+		}
+	}
+	if *disableListingPath != "" {
+		for _, p := range strings.Split(*disableListingPath, ",") {
+			srv.disableListingPaths = append(srv.disableListingPaths, path.Clean("/"+strings.TrimSpace(p)))
+		}
+	}
+	if *spa {
+		srv.spa = true
+		srv.indexFile = "index.html"
+		if *spaPassthroughExts != "" {
+			srv.spaPassthroughExts = map[string]bool{}
+			for _, ext := range strings.Split(*spaPassthroughExts, ",") {
+				srv.spaPassthroughExts[strings.ToLower(strings.TrimSpace(ext))] = true
+			}
+		}
+	}
+	if *sitemapExclude != "" {
+		srv.sitemapExclude = strings.Split(*sitemapExclude, ",")
+	}
+	if srv.feedTitle == "" {
+		srv.feedTitle = filepath.Base(absDir)
+	}
+	srv.cspMode = *cspMode
+	srv.urlPrefix = *prefix
+	srv.filters = filters
+	srv.noReadme = *noReadme
+	srv.tags = *tagsFlag
+	if *auditLogFile != "" {
+		file, err := filepath.Abs(*auditLogFile)
+		if err != nil {
+			log.Fatalf("Invalid -audit-log %s: %v", *auditLogFile, err)
+		}
+		if err := checkOutside(file, layerDirs); err != nil {
+			log.Fatalf("Invalid -audit-log: %v", err)
+		}
+		if srv.audit, err = openAuditLog(file); err != nil {
+			log.Fatalf("Could not open audit log: %v", err)
+		}
+	}
+	// a cached listing would repeat the nonce of the request that rendered it
+	if *listingCacheTTL > 0 && !*verbose && *cspMode != "strict" {
+		srv.listingCache = newListingCache(time.Duration(*listingCacheTTL) * time.Second)
+	}
+	if srv.watcher, err = newDirWatcher(); err != nil {
+		log.Printf("Live directory updates are disabled: %v", err)
+	}
+	http.HandleFunc("/", srv.handleRequest)
+	http.HandleFunc("/_search", srv.handleSearch)
+	http.HandleFunc("/_feed", srv.handleFeed)
+	http.HandleFunc("/_checksum/", srv.handleChecksum)
+	http.HandleFunc("/_color/", srv.handleColor)
+	http.HandleFunc("/_blurhash/", srv.handleBlurhash)
+	http.HandleFunc("/_chapters/", srv.handleChapters)
+	http.HandleFunc("/_api/openapi.json", handleOpenAPI)
+	http.HandleFunc("/_srt2vtt/", srv.handleSRT2VTT)
+	http.HandleFunc("/_waveform/", srv.handleWaveform)
+	http.HandleFunc("/_playlist/", srv.handlePlaylistFile)
+	http.HandleFunc("/_treehash/", srv.handleTreeHash)
+	// tags are written to disk next to the files, which only works when
+	// the files are straight from -dir
+	tagWrites := *tagsFlag && *adminToken != "" && *overlay == "" && !*tempPreview
+	if *tagsFlag {
+		if tagWrites {
+			http.HandleFunc("/_tag", srv.handleTag)
+		} else if *overlay != "" || *tempPreview {
+			log.Printf("Notice: tags can't be changed with -overlay or -temp-preview; /_tag is disabled")
+		}
+		http.HandleFunc("/_tags/", srv.handleTags)
+	}
+	http.HandleFunc("/_ws/", srv.handleWebSocket)
+	http.HandleFunc("/_events/", srv.handleEvents)
+	if *audioMeta {
+		http.HandleFunc("/_cover/", srv.handleCover)
+	}
+
+	// connection limit
+	var limiter *connLimiter
+	if *maxConnections > 0 {
+		limiter = newConnLimiter(*maxConnections)
+	}
+
+	// admin endpoints
+	var stats *serverStats
+	if *adminToken != "" {
+		config := map[string]string{}
+		flag.VisitAll(func(f *flag.Flag) {
+			if !secretFlags[f.Name] {
+				config[f.Name] = f.Value.String()
+			}
+		})
+		config["dir"] = absDir
+		stats = &serverStats{started: time.Now(), slowThreshold: *logSlow}
+		http.Handle("/_admin/", &adminAPI{token: *adminToken, srv: srv, stats: stats, limiter: limiter, config: config})
+
+		srv.adminToken = *adminToken
+		if *shareFile != "" {
+			file, err := filepath.Abs(*shareFile)
+			if err != nil {
+				log.Fatalf("Invalid -share-file %s: %v", *shareFile, err)
+			}
+			if err := checkOutside(file, layerDirs); err != nil {
+				log.Fatalf("Invalid -share-file: %v", err)
+			}
+		}
+		if srv.shares, err = loadShareStore(*shareFile); err != nil {
+			log.Fatalf("Could not load share links from %s: %v", *shareFile, err)
+		}
+		http.HandleFunc("/_share", srv.handleShare)
+		http.HandleFunc("/_dl/", srv.handleDownload)
+	}
+	if *webdavFlag {
+		davPrefix := "/" + strings.Trim(*webdavPrefix, "/")
+		http.Handle(davPrefix+"/", srv.newWebDAVHandler(davPrefix))
+	}
+	if *secretKey != "" {
+		srv.signer = newURLSigner(*secretKey)
+		http.HandleFunc("/_dl/signed/", srv.handleSignedDownload)
+		if *adminToken != "" {
+			http.HandleFunc("/_sign", srv.handleSign)
+		}
+	}
+
+	// request logging
+	var handler http.Handler = http.DefaultServeMux
+	// path rate limits match paths below -prefix, so they go inside it
+	if len(pathLimits) > 0 {
+		handler = limitPaths(handler, pathLimits)
+	}
+	// so does the connection limit, which tells the live update streams
+	// apart by their path
+	if limiter != nil {
+		handler = limiter.limit(handler)
+	}
+	if *prefix != "" {
+		handler = http.StripPrefix(*prefix, handler)
+	}
+	if *verbose || *logUserAgent || *logSlow > 0 {
+		handler = logRequests(handler, *logUserAgent, *verbose || *logUserAgent, *logSlow)
+	}
+	if stats != nil {
+		handler = stats.countRequests(handler)
+	}
+	if len(headers) > 0 {
+		handler = addHeaders(handler, http.Header(headers))
+	}
+	handler = requestIDMiddleware(handler, *trustRequestID)
+	var drain *drainHandler
+	if *shutdownMessage != "" {
+		drain = &drainHandler{next: handler, message: *shutdownMessage}
+		handler = drain
+	}
+	if *h2cFlag {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
 
 	// start server
-	fmt.Printf("Serving directory %s on HTTP port: %d\n", absDir, *port)
-	err = http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
-	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+	httpServer := &http.Server{
+		Handler:           handler,
+		ReadTimeout:       time.Duration(*readTimeout) * time.Second,
+		WriteTimeout:      time.Duration(*writeTimeout) * time.Second,
+		IdleTimeout:       time.Duration(*idleTimeout) * time.Second,
+		ReadHeaderTimeout: time.Duration(*headerTimeout) * time.Second,
+	}
+	if *verbose {
+		log.Printf("Timeouts: read %v, write %v, idle %v, header %v",
+			httpServer.ReadTimeout, httpServer.WriteTimeout, httpServer.IdleTimeout, httpServer.ReadHeaderTimeout)
+	}
+
+	// shut down cleanly on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		// open listing pages would hold their live update streams until
+		// the timeout
+		srv.stopLive()
+		if drain != nil {
+			// keep accepting while the last requests finish, so new ones
+			// get the message instead of a refused connection
+			httpServer.SetKeepAlivesEnabled(false)
+			drain.drain(shutdownCtx)
+		}
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	var listeners []net.Listener
+	if *portRange != "" {
+		listeners, *port, err = listenRange(firstPort, lastPort, *ipv6)
+	} else {
+		listeners, err = listen(*port, *ipv6)
+	}
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE) && *portRange != "":
+		log.Fatalf("Every port from %d to %d is already in use.", firstPort, lastPort)
+	case errors.Is(err, syscall.EADDRINUSE):
+		log.Fatal(portInUse(*port))
+	case err != nil:
+		log.Fatal("Listen: ", err)
+	}
+	httpServer.Addr = fmt.Sprintf(":%d", *port)
+	if !*quiet {
+		b := banner{title: fmt.Sprintf("Serving directory %s on HTTP port: %d", absDir, *port)}
+		if *tempPreview {
+			b.title = fmt.Sprintf("Serving %d bytes from stdin as /payload on HTTP port: %d", payloadSize, *port)
+		}
+		switch {
+		case *portRange == "" || *port == firstPort:
+		case *port == firstPort+1:
+			b.title += fmt.Sprintf(" (%d was busy)", firstPort)
+		default:
+			b.title += fmt.Sprintf(" (%d-%d were busy)", firstPort, *port-1)
+		}
+		var addrs []string
+		for _, ln := range listeners {
+			addrs = append(addrs, ln.Addr().String())
+		}
+		b.add("Listening", strings.Join(addrs, ", "))
+		b.add("URL", fmt.Sprintf("http://localhost:%d%s/", *port, *prefix))
+		if *overlay != "" {
+			b.add("Overlays", strings.Join(layerDirs[:len(layerDirs)-1], ", "))
+		}
+		if *h2cFlag {
+			b.add("Protocols", "HTTP/1.1, HTTP/2 cleartext (h2c)")
+		}
+		b.add("TLS", "off")
+		if tagWrites {
+			b.add("Mode", "read only, except tags set with /_tag")
+		} else {
+			b.add("Mode", "read only")
+		}
+		if *adminToken != "" {
+			b.add("Auth", "bearer token for /_admin/ and /_share")
+		} else {
+			b.add("Auth", "none")
+		}
+		if *webdavFlag {
+			b.add("WebDAV", *prefix+"/"+strings.Trim(*webdavPrefix, "/")+"/ (read only)")
+		}
+		if *proxyOrigin != "" {
+			b.add("Proxy", "missing files from "+*proxyOrigin)
+		}
+		if *disableListing {
+			b.add("Listings", "disabled")
+		} else if *disableListingPath != "" {
+			b.add("Listings", "disabled below "+*disableListingPath)
+		}
+		if *followSymlinks {
+			b.warn("Symlinks", "followed outside the served directory")
+		}
+		b.print(os.Stdout)
+	}
+	if *openFlag {
+		go openBrowser(fmt.Sprintf("http://127.0.0.1:%d%s/", *port, *prefix))
+	}
+	errc := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		if *verbose {
+			log.Printf("Listening on %s", ln.Addr())
+		}
+		go func(ln net.Listener) {
+			errc <- httpServer.Serve(ln)
+		}(ln)
+	}
+
+	err = <-errc
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal("Serve: ", err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+	<-done
+	if srv.downloads != nil {
+		if err := srv.downloads.flush(); err != nil {
+			log.Printf("Could not save download stats: %v", err)
+		}
+	}
+}
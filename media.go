@@ -0,0 +1,62 @@
+package main
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+var audioExtensions = map[string]bool{
+	".mp3": true, ".flac": true, ".ogg": true, ".oga": true, ".opus": true,
+	".m4a": true, ".aac": true, ".wav": true,
+}
+
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+	".bmp": true, ".svg": true, ".avif": true,
+}
+
+var videoExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".webm": true, ".mov": true, ".mkv": true,
+	".ogv": true,
+}
+
+// textExtensions are text files mime doesn't know to be text/.
+var textExtensions = map[string]bool{
+	".md": true, ".json": true, ".yaml": true, ".yml": true, ".toml": true,
+	".log": true, ".sh": true, ".py": true, ".go": true, ".rs": true,
+	".ini": true, ".conf": true, ".srt": true, ".vtt": true,
+}
+
+func isAudioFile(name string) bool {
+	return audioExtensions[strings.ToLower(path.Ext(name))]
+}
+
+func isImageFile(name string) bool {
+	return imageExtensions[strings.ToLower(path.Ext(name))]
+}
+
+func isVideoFile(name string) bool {
+	return videoExtensions[strings.ToLower(path.Ext(name))]
+}
+
+func isTextFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return textExtensions[ext] || strings.HasPrefix(mime.TypeByExtension(ext), "text/")
+}
+
+// previewKind is how the listing previews the file name: "image", "audio",
+// "video" or "text", or "" when it can't.
+func previewKind(name string) string {
+	switch {
+	case isImageFile(name):
+		return "image"
+	case isAudioFile(name):
+		return "audio"
+	case isVideoFile(name):
+		return "video"
+	case isTextFile(name):
+		return "text"
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// listingDisabled reports whether dirPath may not be browsed, because of
+// -disable-listing, because it is below a -disable-listing-path or because
+// -max-dir-depth is 0.
+func (s *server) listingDisabled(dirPath string) bool {
+	if s.disableListing || s.maxDirDepth == 0 {
+		return true
+	}
+	for _, prefix := range s.disableListingPaths {
+		if prefix == "/" || dirPath == prefix || strings.HasPrefix(dirPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// walkListed walks dir like walkFS, leaving out the directories that can't
+// be browsed so endpoints built on walking don't list them either.
+func (s *server) walkListed(dir string, fn func(p string, info fs.FileInfo) error) error {
+	if s.listingDisabled(dir) {
+		return nil
+	}
+	return walkFS(s.root, dir, func(p string, info fs.FileInfo) error {
+		if info.IsDir() && s.listingDisabled(p) {
+			return fs.SkipDir
+		}
+		return fn(p, info)
+	})
+}
+
+// pathDepth is the depth of the directory at p, or of the directory holding
+// the file at p: 0 for the root, 1 one level down, and so on.
+func pathDepth(p string, isDir bool) int {
+	depth := strings.Count(strings.Trim(p, "/"), "/")
+	if p != "/" && isDir {
+		depth++
+	}
+	return depth
+}
+
+// tooDeep reports whether p is below -max-dir-depth.
+func (s *server) tooDeep(p string, isDir bool) bool {
+	return s.maxDirDepth >= 0 && pathDepth(p, isDir) > s.maxDirDepth
+}
+
+// errTooDeep is what depthFS answers for paths below -max-dir-depth.
+var errTooDeep = fmt.Errorf("maximum directory depth exceeded: %w", fs.ErrPermission)
+
+// depthFS refuses to open directories deeper than -max-dir-depth, or
+// anything in them, and leaves those directories out of directory listings.
+type depthFS struct {
+	http.FileSystem
+	max int
+}
+
+func (fsys depthFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	if pathDepth(name, false) > fsys.max {
+		return nil, errTooDeep
+	}
+	f, err := fsys.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || !info.IsDir() {
+		return f, nil
+	}
+	if pathDepth(name, true) > fsys.max {
+		f.Close()
+		return nil, errTooDeep
+	}
+	// subdirectories of a directory at the limit are past it
+	return depthFile{File: f, leaves: pathDepth(name, true) == fsys.max}, nil
+}
+
+type depthFile struct {
+	http.File
+	leaves bool
+}
+
+func (f depthFile) Readdir(count int) ([]fs.FileInfo, error) {
+	for {
+		entries, err := f.File.Readdir(count)
+		if !f.leaves {
+			return entries, err
+		}
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				kept = append(kept, entry)
+			}
+		}
+		// an empty batch would be taken for the end of the directory
+		if count <= 0 || len(kept) > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// ReadDir is Readdir for callers that stat entries themselves.
+func (f depthFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	rd, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		infos, err := f.Readdir(count)
+		entries := make([]fs.DirEntry, len(infos))
+		for i, info := range infos {
+			entries[i] = fs.FileInfoToDirEntry(info)
+		}
+		return entries, err
+	}
+	for {
+		entries, err := rd.ReadDir(count)
+		if !f.leaves {
+			return entries, err
+		}
+		kept := entries[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				kept = append(kept, entry)
+			}
+		}
+		if count <= 0 || len(kept) > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
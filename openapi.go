@@ -0,0 +1,535 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+type object = map[string]any
+
+var openAPISpec = sync.OnceValue(buildOpenAPISpec)
+
+func pathParam(description string) object {
+	return object{
+		"name":        "path",
+		"in":          "path",
+		"required":    true,
+		"description": description,
+		"schema":      object{"type": "string"},
+	}
+}
+
+func queryParam(name, typ, description string) object {
+	return object{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      object{"type": typ},
+	}
+}
+
+func textResponse(description, contentType string) object {
+	return object{
+		"description": description,
+		"content":     object{contentType: object{"schema": object{"type": "string"}}},
+	}
+}
+
+func jsonResponse(description string, schema object) object {
+	return object{
+		"description": description,
+		"content":     object{"application/json": object{"schema": schema}},
+	}
+}
+
+// buildOpenAPISpec describes the endpoints scripts can use besides plain file
+// downloads and listings.
+func buildOpenAPISpec() []byte {
+	spec := object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "Simple HTTP Server",
+			"version": "1.0.0",
+		},
+		"paths": object{
+			"/_search": object{
+				"get": object{
+					"summary": "Search the served directory",
+					"parameters": []object{
+						{"name": "q", "in": "query", "required": true, "description": "text to look for", "schema": object{"type": "string"}},
+						queryParam("regex", "boolean", "treat q as a regular expression"),
+						queryParam("content", "boolean", "also search the contents of text files"),
+						queryParam("ext", "string", "comma separated extensions to limit content search to, like .go,.md"),
+						queryParam("case_sensitive", "boolean", "match q as a case-sensitive substring"),
+					},
+					"responses": object{
+						"200": object{
+							"description": "At most 200 matches",
+							"content": object{"application/json": object{"schema": object{
+								"type":  "array",
+								"items": object{"$ref": "#/components/schemas/SearchResult"},
+							}}},
+						},
+						"400": textResponse("Missing query or invalid regular expression", "text/plain"),
+					},
+				},
+			},
+			"/_events/{path}": object{
+				"get": object{
+					"summary":    "Follow changes to a directory as server-sent events",
+					"parameters": []object{pathParam("directory to follow")},
+					"responses": object{
+						"200": object{
+							"description": "A stream of events whose data is a DirEvent",
+							"content": object{"text/event-stream": object{"schema": object{
+								"$ref": "#/components/schemas/DirEvent",
+							}}},
+						},
+						"404": textResponse("Not a directory", "text/plain"),
+						"503": textResponse("Directory watching is not available", "text/plain"),
+					},
+				},
+			},
+			"/_checksum/{path}": object{
+				"get": object{
+					"summary": "Hash a file",
+					"parameters": []object{
+						pathParam("file to hash"),
+						{"name": "algo", "in": "query", "description": "hash to use", "schema": object{"type": "string", "enum": []string{"sha256", "sha1", "md5", "blake3"}, "default": "sha256"}},
+					},
+					"responses": object{
+						"200": object{
+							"description": "The checksum",
+							"content":     object{"application/json": object{"schema": object{"$ref": "#/components/schemas/Checksum"}}},
+						},
+						"400": textResponse("Unsupported algo", "text/plain"),
+						"403": textResponse("Path outside the served directory", "text/plain"),
+						"404": textResponse("Not a file", "text/plain"),
+					},
+				},
+			},
+			"/_srt2vtt/{path}": object{
+				"get": object{
+					"summary":    "Convert SubRip subtitles to WebVTT",
+					"parameters": []object{pathParam(".srt file to convert")},
+					"responses": object{
+						"200": textResponse("The subtitles as WebVTT", "text/vtt"),
+						"404": textResponse("Not an .srt file", "text/plain"),
+					},
+				},
+			},
+			"/_cover/{path}": object{
+				"get": object{
+					"summary":     "Cover art embedded in an audio file",
+					"description": "Only available with -audio-meta.",
+					"parameters":  []object{pathParam("audio file")},
+					"responses": object{
+						"200": object{
+							"description": "The embedded picture",
+							"content": object{"image/*": object{"schema": object{
+								"type":   "string",
+								"format": "binary",
+							}}},
+						},
+						"404": textResponse("No cover art", "text/plain"),
+					},
+				},
+			},
+			"/_feed": object{
+				"get": object{
+					"summary": "RSS feed of the most recently modified files",
+					"parameters": []object{
+						queryParam("path", "string", "directory to cover, the whole served tree by default"),
+					},
+					"responses": object{
+						"200": textResponse("The 20 most recently modified files", "application/rss+xml"),
+						"304": object{"description": "Nothing changed since the ETag sent in If-None-Match"},
+						"403": textResponse("Listing disabled", "text/plain"),
+						"404": textResponse("Not a directory", "text/plain"),
+					},
+				},
+			},
+			"/_admin/config": object{
+				"get": object{
+					"summary":     "The server's flag values",
+					"description": "Only available with -admin-token. Secrets are left out.",
+					"security":    []object{{"adminToken": []string{}}},
+					"responses": object{
+						"200": jsonResponse("Flag values by name", object{"type": "object", "additionalProperties": object{"type": "string"}}),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+					},
+				},
+			},
+			"/_admin/stats": object{
+				"get": object{
+					"summary":     "Request and cache counters since startup",
+					"description": "Only available with -admin-token.",
+					"security":    []object{{"adminToken": []string{}}},
+					"responses": object{
+						"200": jsonResponse("The counters", object{"$ref": "#/components/schemas/AdminStats"}),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+					},
+				},
+			},
+			"/_admin/cache/clear": object{
+				"post": object{
+					"summary":     "Empty the listing cache",
+					"description": "Only available with -admin-token.",
+					"security":    []object{{"adminToken": []string{}}},
+					"responses": object{
+						"200": jsonResponse("Whether there was a cache to clear", object{
+							"type":       "object",
+							"properties": object{"cleared": object{"type": "boolean"}},
+						}),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+					},
+				},
+			},
+			"/_share": object{
+				"get": object{
+					"summary":     "List the share links that haven't expired",
+					"description": "Only available with -admin-token.",
+					"security":    []object{{"adminToken": []string{}}},
+					"responses": object{
+						"200": jsonResponse("The share links", object{
+							"type":  "array",
+							"items": object{"$ref": "#/components/schemas/ShareInfo"},
+						}),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+					},
+				},
+				"post": object{
+					"summary":     "Create a share link for a file",
+					"description": "Only available with -admin-token.",
+					"security":    []object{{"adminToken": []string{}}},
+					"requestBody": object{
+						"required": true,
+						"content": object{"application/json": object{"schema": object{
+							"type":     "object",
+							"required": []string{"path"},
+							"properties": object{
+								"path":           object{"type": "string"},
+								"expiry_seconds": object{"type": "integer", "format": "int64", "description": "0 for a link that doesn't expire"},
+								"password":       object{"type": "string", "description": "asked for with Basic authentication when set"},
+							},
+						}}},
+					},
+					"responses": object{
+						"201": jsonResponse("The new link", object{
+							"type": "object",
+							"properties": object{
+								"token": object{"type": "string"},
+								"url":   object{"type": "string"},
+							},
+						}),
+						"400": textResponse("Invalid body or not a file", "text/plain"),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+					},
+				},
+			},
+			"/_dl/{token}": object{
+				"get": object{
+					"summary": "Download the file behind a share link",
+					"parameters": []object{
+						{"name": "token", "in": "path", "required": true, "description": "share link token", "schema": object{"type": "string"}},
+					},
+					"responses": object{
+						"200": object{
+							"description": "The file, as an attachment",
+							"content":     object{"application/octet-stream": object{"schema": object{"type": "string", "format": "binary"}}},
+						},
+						"401": textResponse("Missing or wrong password", "text/plain"),
+						"404": textResponse("Unknown link", "text/plain"),
+						"410": textResponse("Expired link", "text/plain"),
+					},
+				},
+			},
+			"/_sign": object{
+				"get": object{
+					"summary":     "Sign an expiring download URL for a file",
+					"description": "Only available with -secret-key and -admin-token.",
+					"security":    []object{{"adminToken": []string{}}},
+					"parameters": []object{
+						{"name": "path", "in": "query", "required": true, "description": "file to sign a URL for", "schema": object{"type": "string"}},
+						{"name": "ttl", "in": "query", "description": "seconds the URL stays valid", "schema": object{"type": "integer", "default": 3600}},
+					},
+					"responses": object{
+						"200": jsonResponse("The signed URL", object{
+							"type": "object",
+							"properties": object{
+								"url":     object{"type": "string"},
+								"expires": object{"type": "string", "format": "date-time"},
+							},
+						}),
+						"400": textResponse("Not a file or invalid ttl", "text/plain"),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+					},
+				},
+			},
+			"/_dl/signed/{signature}": object{
+				"get": object{
+					"summary":     "Download the file a signed URL was made for",
+					"description": "Only available with -secret-key.",
+					"parameters": []object{
+						{"name": "signature", "in": "path", "required": true, "description": "what /_sign returned after /_dl/signed/", "schema": object{"type": "string"}},
+					},
+					"responses": object{
+						"200": object{
+							"description": "The file, as an attachment",
+							"content":     object{"application/octet-stream": object{"schema": object{"type": "string", "format": "binary"}}},
+						},
+						"403": textResponse("Invalid signature", "text/plain"),
+						"410": textResponse("Expired URL", "text/plain"),
+					},
+				},
+			},
+			"/_color/{path}": object{
+				"get": object{
+					"summary":    "Average color of an image, for placeholders",
+					"parameters": []object{pathParam("JPEG, PNG or GIF image")},
+					"responses": object{
+						"200": jsonResponse("The color", object{
+							"type":       "object",
+							"properties": object{"hex": object{"type": "string", "example": "#a3b2c1"}},
+						}),
+						"404": textResponse("Not an image", "text/plain"),
+						"413": textResponse("Image too large to decode", "text/plain"),
+						"415": textResponse("Unsupported image format", "text/plain"),
+					},
+				},
+			},
+			"/_waveform/{path}": object{
+				"get": object{
+					"summary": "Peaks of an audio file, for drawing its waveform",
+					"parameters": []object{
+						pathParam("audio file"),
+						{"name": "points", "in": "query", "description": "number of peaks", "schema": object{"type": "integer", "minimum": 1, "maximum": 2000, "default": 200}},
+					},
+					"responses": object{
+						"200": jsonResponse("Peaks between 0 and 1", object{
+							"type":  "array",
+							"items": object{"type": "number"},
+						}),
+						"400": textResponse("Invalid points", "text/plain"),
+						"404": textResponse("Not an audio file", "text/plain"),
+						"501": textResponse("ffmpeg is not installed", "text/plain"),
+					},
+				},
+			},
+			"/_playlist/{path}": object{
+				"get": object{
+					"summary": "Playlist of a directory's audio files",
+					"parameters": []object{
+						pathParam("directory"),
+						{"name": "playlist", "in": "query", "description": "playlist format", "schema": object{"type": "string", "enum": []string{"m3u8", "pls"}, "default": "m3u8"}},
+					},
+					"responses": object{
+						"200": object{
+							"description": "The playlist",
+							"content": object{
+								"audio/x-mpegurl": object{"schema": object{"type": "string"}},
+								"audio/x-scpls":   object{"schema": object{"type": "string"}},
+							},
+						},
+						"400": textResponse("Unknown playlist format", "text/plain"),
+						"403": textResponse("Listing disabled", "text/plain"),
+						"404": textResponse("Not a directory", "text/plain"),
+					},
+				},
+			},
+			"/_treehash/{path}": object{
+				"get": object{
+					"summary":     "Merkle root over the files below a directory",
+					"description": "Leaves are the BLAKE2b-256 of a 0x00 byte, each file's relative path, a zero byte and the BLAKE2b-256 of its contents, in path order. Each parent is the BLAKE2b-256 of a 0x01 byte and its two children, so leaves and parents never hash alike (as in RFC 6962); an odd node out is carried up unchanged. An empty tree's root is the BLAKE2b-256 of nothing.",
+					"parameters": []object{
+						pathParam("directory"),
+						queryParam("follow_symlinks", "boolean", "include symlinked files"),
+					},
+					"responses": object{
+						"200": jsonResponse("The root", object{
+							"type":     "object",
+							"required": []string{"root", "files", "algo"},
+							"properties": object{
+								"root":  object{"type": "string"},
+								"files": object{"type": "integer"},
+								"algo":  object{"type": "string"},
+							},
+						}),
+						"403": textResponse("Listing disabled or path outside the served directory", "text/plain"),
+						"404": textResponse("Not a directory", "text/plain"),
+					},
+				},
+			},
+			"/_tag": object{
+				"post": object{
+					"summary":     "Set the tags of a file",
+					"description": "Only available with -tags and -admin-token, and not with -overlay or -temp-preview. An empty list removes the tags.",
+					"security":    []object{{"adminToken": []string{}}},
+					"requestBody": object{
+						"required": true,
+						"content":  object{"application/json": object{"schema": object{"$ref": "#/components/schemas/Tags"}}},
+					},
+					"responses": object{
+						"200": jsonResponse("The tags as saved", object{"$ref": "#/components/schemas/Tags"}),
+						"400": textResponse("Invalid body, too many tags or an invalid tag", "text/plain"),
+						"401": textResponse("Missing or wrong admin token", "text/plain"),
+						"404": textResponse("No such file", "text/plain"),
+					},
+				},
+			},
+			"/_tags/{path}": object{
+				"get": object{
+					"summary":     "The tags of a file",
+					"description": "Only available with -tags.",
+					"parameters":  []object{pathParam("file")},
+					"responses": object{
+						"200": jsonResponse("The tags", object{"$ref": "#/components/schemas/Tags"}),
+						"404": textResponse("Not a file", "text/plain"),
+					},
+				},
+			},
+			"/_blurhash/{path}": object{
+				"get": object{
+					"summary":    "Blurhash of an image, for placeholders",
+					"parameters": []object{pathParam("JPEG, PNG or GIF image")},
+					"responses": object{
+						"200": jsonResponse("The blurhash of a copy scaled down to 32 pixels wide", object{
+							"type":     "object",
+							"required": []string{"blurhash", "width", "height"},
+							"properties": object{
+								"blurhash": object{"type": "string"},
+								"width":    object{"type": "integer"},
+								"height":   object{"type": "integer"},
+							},
+						}),
+						"400": textResponse("Not an image", "text/plain"),
+						"404": textResponse("No such file", "text/plain"),
+						"413": textResponse("Image too large to decode", "text/plain"),
+						"415": textResponse("Unsupported image format", "text/plain"),
+					},
+				},
+			},
+			"/_chapters/{path}": object{
+				"get": object{
+					"summary":    "Chapters of a video from its .chapters.json sidecar",
+					"parameters": []object{pathParam("video file")},
+					"responses": object{
+						"200": jsonResponse("The chapters", object{
+							"type": "array",
+							"items": object{
+								"type": "object",
+								"properties": object{
+									"start_ms": object{"type": "integer", "format": "int64"},
+									"title":    object{"type": "string"},
+								},
+							},
+						}),
+						"404": textResponse("Not a video or no chapters", "text/plain"),
+					},
+				},
+			},
+			"/_api/openapi.json": object{
+				"get": object{
+					"summary": "This document",
+					"responses": object{
+						"200": object{
+							"description": "OpenAPI 3.0 description of the API",
+							"content":     object{"application/json": object{"schema": object{"type": "object"}}},
+						},
+					},
+				},
+			},
+		},
+		"components": object{
+			"schemas": object{
+				"SearchResult": object{
+					"type":     "object",
+					"required": []string{"path", "name", "size", "mod_time", "match_type"},
+					"properties": object{
+						"path":       object{"type": "string"},
+						"name":       object{"type": "string"},
+						"size":       object{"type": "integer", "format": "int64"},
+						"mod_time":   object{"type": "string", "format": "date-time"},
+						"match_type": object{"type": "string", "enum": []string{"filename", "content"}},
+						"line":       object{"type": "integer", "description": "number of the matching line, for content matches"},
+						"text":       object{"type": "string", "description": "the matching line cut to about 200 bytes around the match, for content matches"},
+					},
+				},
+				"Checksum": object{
+					"type":     "object",
+					"required": []string{"algo", "hex", "size", "name"},
+					"properties": object{
+						"algo": object{"type": "string"},
+						"hex":  object{"type": "string"},
+						"size": object{"type": "integer", "format": "int64"},
+						"name": object{"type": "string"},
+					},
+				},
+				"DirEvent": object{
+					"type":     "object",
+					"required": []string{"event", "name", "is_dir"},
+					"properties": object{
+						"event":  object{"type": "string", "enum": []string{"created", "deleted", "modified"}},
+						"name":   object{"type": "string"},
+						"is_dir": object{"type": "boolean"},
+					},
+				},
+				"AdminStats": object{
+					"type": "object",
+					"properties": object{
+						"uptime_seconds":     object{"type": "integer", "format": "int64"},
+						"requests":           object{"type": "integer", "format": "int64"},
+						"bot_requests":       object{"type": "integer", "format": "int64"},
+						"slow_requests":      object{"type": "integer", "format": "int64"},
+						"bytes_served":       object{"type": "integer", "format": "int64"},
+						"active_connections": object{"type": "integer"},
+						"listing_cache": object{
+							"type": "object",
+							"properties": object{
+								"enabled":  object{"type": "boolean"},
+								"hits":     object{"type": "integer", "format": "int64"},
+								"misses":   object{"type": "integer", "format": "int64"},
+								"hit_rate": object{"type": "number"},
+							},
+						},
+						"downloads": object{"type": "object", "additionalProperties": object{"type": "integer", "format": "int64"}},
+					},
+				},
+				"ShareInfo": object{
+					"type":     "object",
+					"required": []string{"token", "path", "url", "created", "has_password"},
+					"properties": object{
+						"token":        object{"type": "string"},
+						"path":         object{"type": "string"},
+						"url":          object{"type": "string"},
+						"created":      object{"type": "string", "format": "date-time"},
+						"expires":      object{"type": "string", "format": "date-time"},
+						"has_password": object{"type": "boolean"},
+					},
+				},
+				"Tags": object{
+					"type":     "object",
+					"required": []string{"path", "tags"},
+					"properties": object{
+						"path": object{"type": "string"},
+						"tags": object{"type": "array", "items": object{"type": "string"}},
+					},
+				},
+			},
+			"securitySchemes": object{
+				"adminToken": object{"type": "http", "scheme": "bearer", "description": "the -admin-token"},
+			},
+		},
+	}
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec())
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// overlayFS stacks file systems: a path is opened from the first layer that
+// has it, and directories list the entries of that directory in every
+// layer, with earlier layers winning when names collide.
+type overlayFS struct {
+	layers []http.FileSystem
+}
+
+func (fsys overlayFS) Open(name string) (http.File, error) {
+	var first http.File
+	var firstErr error
+	var lower []http.File
+	for _, layer := range fsys.layers {
+		f, err := layer.Open(name)
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, fs.ErrNotExist) {
+				firstErr = err
+			}
+			continue
+		}
+		if first == nil {
+			first = f
+			if info, err := f.Stat(); err != nil || !info.IsDir() {
+				return f, nil
+			}
+			continue
+		}
+		// only directories below the first hit are merged into it
+		if info, err := f.Stat(); err == nil && info.IsDir() {
+			lower = append(lower, f)
+		} else {
+			f.Close()
+		}
+	}
+	if first == nil {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, os.ErrNotExist
+	}
+	if len(lower) == 0 {
+		return first, nil
+	}
+	return &overlayDir{File: first, lower: lower}, nil
+}
+
+// overlayDir is a directory present in more than one layer.
+type overlayDir struct {
+	http.File
+	lower   []http.File
+	entries []fs.FileInfo
+	read    bool
+}
+
+func (d *overlayDir) Close() error {
+	for _, f := range d.lower {
+		f.Close()
+	}
+	return d.File.Close()
+}
+
+func (d *overlayDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.read {
+		d.read = true
+		seen := map[string]bool{}
+		for _, f := range append([]http.File{d.File}, d.lower...) {
+			entries, err := f.Readdir(-1)
+			if err != nil && len(entries) == 0 {
+				continue
+			}
+			for _, entry := range entries {
+				if !seen[entry.Name()] {
+					seen[entry.Name()] = true
+					d.entries = append(d.entries, entry)
+				}
+			}
+		}
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// pathRateLimit limits all requests below prefix together, whoever sends
+// them.
+type pathRateLimit struct {
+	prefix  string
+	limiter *rate.Limiter
+}
+
+// pathRateFlag collects the -path-rate-limit rules.
+type pathRateFlag []pathRateLimit
+
+func (f *pathRateFlag) String() string {
+	var rules []string
+	for _, l := range *f {
+		rules = append(rules, fmt.Sprintf("%s=%g", l.prefix, float64(l.limiter.Limit())))
+	}
+	return strings.Join(rules, ",")
+}
+
+func (f *pathRateFlag) Set(s string) error {
+	prefix, value, ok := strings.Cut(s, "=")
+	rps, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if !ok || !strings.HasPrefix(prefix, "/") || err != nil || rps <= 0 {
+		return fmt.Errorf("invalid path rate limit %q, want \"/prefix=requests per second\"", s)
+	}
+	*f = append(*f, pathRateLimit{
+		prefix:  path.Clean(prefix),
+		limiter: rate.NewLimiter(rate.Limit(rps), int(math.Ceil(rps))),
+	})
+	return nil
+}
+
+// matches reports whether urlPath is prefix or below it.
+func (l pathRateLimit) matches(urlPath string) bool {
+	if l.prefix == "/" {
+		return true
+	}
+	return urlPath == l.prefix || strings.HasPrefix(urlPath, l.prefix+"/")
+}
+
+// limitPaths answers 429 to requests that go over the limit of any rule
+// whose prefix they are below; paths no rule matches are left alone.
+func limitPaths(next http.Handler, limits []pathRateLimit) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := path.Clean("/" + r.URL.Path)
+		for _, l := range limits {
+			if l.matches(urlPath) && !l.limiter.Allow() {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests, try again shortly", http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
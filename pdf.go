@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type pdfViewerData struct {
+	Name        string
+	Src         string
+	DownloadURL string
+	ParentURL   string
+	Nonce       string
+}
+
+var pdfViewerTemplate = template.Must(template.New("pdf").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+<title>{{.Name}}</title>
+<style{{with .Nonce}} nonce="{{.}}"{{end}}>
+html, body { margin: 0; height: 100%; font-family: sans-serif; }
+header { display: flex; align-items: center; gap: 12px; height: 48px; padding: 0 12px; box-sizing: border-box; background: #333; color: #eee; }
+header a { color: #9cf; }
+header h1 { flex: 1; margin: 0; font-size: 16px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+#viewer { display: block; width: 100%; height: calc(100vh - 48px); border: 0; }
+</style>
+</head>
+<body>
+<header>
+<a href="{{.ParentURL}}">&larr;</a>
+<h1>{{.Name}}</h1>
+<a href="{{.DownloadURL}}" download>Download</a>
+</header>
+<embed id="viewer" src="{{.Src}}" type="application/pdf" width="100%" height="100vh">
+<script{{with .Nonce}} nonce="{{.}}"{{end}}>
+// fall back to an iframe when the browser won't render PDFs in an embed
+if (navigator.pdfViewerEnabled === false) {
+  var embed = document.getElementById("viewer");
+  var frame = document.createElement("iframe");
+  frame.id = "viewer";
+  frame.src = embed.getAttribute("src");
+  embed.replaceWith(frame);
+}
+</script>
+</body>
+</html>
+`))
+
+// wantsPDFViewer reports whether the request for the file at p should get
+// the viewer page rather than the PDF itself: a browser navigating to a PDF
+// without ?download. As that depends on Accept, it marks the response as
+// varying on it for every PDF.
+func (s *server) wantsPDFViewer(w http.ResponseWriter, r *http.Request, p string) bool {
+	if s.noPDFViewer || mime.TypeByExtension(strings.ToLower(path.Ext(p))) != "application/pdf" {
+		return false
+	}
+	w.Header().Add("Vary", "Accept")
+	if r.Method != http.MethodGet || r.URL.Query().Has("download") {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// renderPDFViewer renders a page that shows the PDF at p inline.
+func (s *server) renderPDFViewer(w http.ResponseWriter, r *http.Request, p string) {
+	parent := path.Dir(p)
+	if parent != "/" {
+		parent += "/"
+	}
+	data := pdfViewerData{
+		Name:        path.Base(p),
+		DownloadURL: s.urlPath(p) + "?download",
+		ParentURL:   s.urlPath(parent),
+	}
+	data.Src = data.DownloadURL
+	if page, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && page > 0 {
+		data.Src += "#page=" + strconv.Itoa(page)
+	}
+
+	data.Nonce = s.setCSP(w)
+	var buf bytes.Buffer
+	if err := pdfViewerTemplate.Execute(&buf, data); err != nil {
+		log.Printf("Could not render PDF viewer for %s: %v", p, err)
+		http.Error(w, "Error rendering PDF viewer", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// writePIDFile writes the current process ID to file. It refuses to replace
+// a PID file whose process is still running unless force is set.
+func writePIDFile(file string, force bool) error {
+	if data, err := os.ReadFile(file); err == nil && !force {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("PID file %s belongs to running process %d, use -force to start anyway", file, pid)
+		}
+	}
+	return os.WriteFile(file, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
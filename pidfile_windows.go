@@ -0,0 +1,15 @@
+//go:build windows
+
+package main
+
+import "os"
+
+func processRunning(pid int) bool {
+	// FindProcess opens a handle to the process, which fails if it is gone
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+type playlistTrack struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Waveform string `json:"waveform,omitempty"`
+}
+
+type playlistData struct {
+	Path    string
+	URL     string
+	Tracks  []playlistTrack
+	Shuffle bool
+	Nonce   string
+}
+
+var playlistTemplate = template.Must(template.New("playlist").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+<title>Playlist for {{.Path}}</title>
+<style{{with .Nonce}} nonce="{{.}}"{{end}}>
+audio { width: 100%; }
+#waveform { display: block; width: 100%; height: 60px; cursor: pointer; }
+#waveform path { fill: #69c; }
+#tracks li { cursor: pointer; padding: 2px 4px; }
+#tracks li.current { font-weight: bold; background: #def; }
+</style>
+</head>
+<body>
+<h1>Playlist for {{.Path}}</h1>
+<p><a href="{{.URL}}">Back to listing</a></p>
+{{- if .Tracks}}
+<audio id="player" controls></audio>
+<svg id="waveform" viewBox="0 0 1 100" preserveAspectRatio="none" hidden><path></path></svg>
+<ul id="tracks"></ul>
+{{- else}}
+<p>No audio files in this directory.</p>
+{{- end}}
+<script{{with .Nonce}} nonce="{{.}}"{{end}}>
+(function () {
+  var tracks = {{.Tracks}};
+  if (!tracks || !tracks.length) return;
+  var shuffle = {{.Shuffle}};
+  var key = "playlist:" + location.pathname + (shuffle ? ":shuffle" : "");
+  var saved = {};
+  try { saved = JSON.parse(sessionStorage.getItem(key)) || {}; } catch (e) {}
+
+  // the shuffled order is saved too, so a reload resumes the same track
+  var order = tracks.map(function (_, i) { return i; });
+  if (shuffle) {
+    if (saved.order && saved.order.length === tracks.length) {
+      order = saved.order;
+    } else {
+      for (var i = order.length - 1; i > 0; i--) {
+        var j = Math.floor(Math.random() * (i + 1));
+        var t = order[i]; order[i] = order[j]; order[j] = t;
+      }
+    }
+  }
+
+  var player = document.getElementById("player");
+  var list = document.getElementById("tracks");
+  var current = 0;
+  order.forEach(function (track, pos) {
+    var li = document.createElement("li");
+    li.textContent = tracks[track].title;
+    li.onclick = function () { play(pos, 0, true); };
+    list.appendChild(li);
+  });
+
+  function save() {
+    sessionStorage.setItem(key, JSON.stringify({order: order, track: current, time: player.currentTime}));
+  }
+
+  function play(pos, time, autoplay) {
+    current = pos;
+    Array.prototype.forEach.call(list.children, function (li, i) {
+      li.className = i === pos ? "current" : "";
+    });
+    player.src = tracks[order[pos]].url;
+    showWaveform(tracks[order[pos]].waveform);
+    if (time) {
+      player.addEventListener("loadedmetadata", function seek() {
+        player.removeEventListener("loadedmetadata", seek);
+        player.currentTime = time;
+      });
+    }
+    if (autoplay) player.play();
+    save();
+  }
+
+  // the waveform is drawn as bars mirrored around the middle, and clicking
+  // it seeks to that point of the track
+  var waveform = document.getElementById("waveform");
+  function showWaveform(url) {
+    waveform.hidden = true;
+    if (!url || !window.fetch) return;
+    fetch(url).then(function (res) { return res.ok ? res.json() : null; }).then(function (peaks) {
+      // another track may have started in the meantime
+      if (!peaks || tracks[order[current]].waveform !== url) return;
+      var d = peaks.map(function (p, i) {
+        var h = Math.max(p * 100, 1);
+        return "M" + i + " " + (50 - h / 2) + "h1v" + h + "h-1z";
+      }).join("");
+      waveform.setAttribute("viewBox", "0 0 " + peaks.length + " 100");
+      waveform.firstChild.setAttribute("d", d);
+      waveform.hidden = false;
+    });
+  }
+  waveform.addEventListener("click", function (e) {
+    var rect = waveform.getBoundingClientRect();
+    if (player.duration) player.currentTime = (e.clientX - rect.left) / rect.width * player.duration;
+  });
+
+  player.addEventListener("ended", function () {
+    if (current + 1 < order.length) play(current + 1, 0, true);
+  });
+  player.addEventListener("timeupdate", save);
+
+  var start = saved.track >= 0 && saved.track < order.length ? saved.track : 0;
+  play(start, saved.time || 0, false);
+})();
+</script>
+</body>
+</html>
+`))
+
+// renderPlaylist renders a page that plays the audio files of dir one after
+// the other.
+func (s *server) renderPlaylist(w http.ResponseWriter, r *http.Request, dirPath string, dir http.File) {
+	files, ok := s.listFilteredFiles(w, r, dirPath, dir)
+	if !ok {
+		return
+	}
+
+	data := playlistData{
+		Path:    dirPath,
+		URL:     s.urlPath(dirPath),
+		Shuffle: r.URL.Query().Get("shuffle") == "true",
+	}
+	if dirPath != "/" {
+		data.Path += "/"
+		data.URL += "/"
+	}
+	_, err := exec.LookPath("ffmpeg")
+	hasFFmpeg := err == nil
+	for _, f := range files {
+		if !f.IsAudio {
+			continue
+		}
+		title := f.Name
+		if f.AudioTitle != "" {
+			title = f.AudioTitle
+			if f.AudioArtist != "" {
+				title += " · " + f.AudioArtist
+			}
+		}
+		track := playlistTrack{Title: title, URL: f.Path}
+		if hasFFmpeg {
+			track.Waveform = s.urlPrefix + "/_waveform" + strings.TrimPrefix(f.Path, s.urlPrefix)
+		}
+		data.Tracks = append(data.Tracks, track)
+	}
+
+	data.Nonce = s.setCSP(w)
+	var buf bytes.Buffer
+	if err := playlistTemplate.Execute(&buf, data); err != nil {
+		log.Printf("Could not render playlist for %s: %v", dirPath, err)
+		http.Error(w, "Error rendering playlist", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// playlistFormats are the content types of the playlist files ?playlist=
+// can ask for.
+var playlistFormats = map[string]string{
+	"m3u8": "audio/x-mpegurl",
+	"pls":  "audio/x-scpls",
+}
+
+// servePlaylistFile serves the audio files of dir as an M3U8 or PLS
+// playlist of absolute URLs, for players like VLC to queue.
+func (s *server) servePlaylistFile(w http.ResponseWriter, r *http.Request, dirPath string, dir http.File, format string) {
+	contentType, ok := playlistFormats[format]
+	if !ok {
+		http.Error(w, "Unknown playlist format, use m3u8 or pls", http.StatusBadRequest)
+		return
+	}
+	files, ok := s.listFilteredFiles(w, r, dirPath, dir)
+	if !ok {
+		return
+	}
+
+	base := baseURL(r)
+	var buf bytes.Buffer
+	n := 0
+	if format == "m3u8" {
+		buf.WriteString("#EXTM3U\n")
+	} else {
+		buf.WriteString("[playlist]\n")
+	}
+	for _, f := range files {
+		if !f.IsAudio {
+			continue
+		}
+		// duration isn't known without decoding the file, hence -1
+		title := strings.TrimSuffix(f.Name, path.Ext(f.Name))
+		if meta, err := s.readAudioMeta(path.Join(dirPath, f.Name), false); err == nil && meta.Title != "" {
+			title = meta.Title
+			if meta.Artist != "" {
+				title = meta.Artist + " - " + meta.Title
+			}
+		}
+		title = strings.NewReplacer("\r", " ", "\n", " ").Replace(title)
+		n++
+		if format == "m3u8" {
+			fmt.Fprintf(&buf, "#EXTINF:-1,%s\n%s\n", title, base+f.Path)
+		} else {
+			fmt.Fprintf(&buf, "File%d=%s\nTitle%d=%s\nLength%d=-1\n", n, base+f.Path, n, title, n)
+		}
+	}
+	if format == "pls" {
+		fmt.Fprintf(&buf, "NumberOfEntries=%d\nVersion=2\n", n)
+	}
+
+	name := path.Base(dirPath)
+	if dirPath == "/" {
+		name = "playlist"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": name + "." + format}))
+	w.Write(buf.Bytes())
+}
+
+// handlePlaylistFile serves the playlist file of the directory following
+// /_playlist, in the ?playlist= format or M3U8.
+func (s *server) handlePlaylistFile(w http.ResponseWriter, r *http.Request) {
+	dirPath := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_playlist"))
+	dir, err := s.root.Open(dirPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer dir.Close()
+	if info, err := dir.Stat(); err != nil || !info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	if s.listingDisabled(dirPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	format := r.URL.Query().Get("playlist")
+	if format == "" {
+		format = "m3u8"
+	}
+	s.servePlaylistFile(w, r, dirPath, dir, format)
+}
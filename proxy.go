@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var proxyClient = &http.Client{Timeout: 30 * time.Second}
+
+// proxiedHeaders are copied from the origin's response to the client.
+var proxiedHeaders = []string{"Content-Type", "Content-Length", "Cache-Control", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"}
+
+// reverseProxyFallback streams p from the -proxy-origin server, for files
+// that don't exist locally. Only the origin configured at startup is ever
+// contacted, with the cleaned request path.
+func (s *server) reverseProxyFallback(w http.ResponseWriter, r *http.Request, p string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target := strings.TrimSuffix(s.proxyOrigin, "/") + (&url.URL{Path: p}).EscapedPath()
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target, nil)
+	if err != nil {
+		http.Error(w, "Bad gateway", http.StatusBadGateway)
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" {
+		req.Header.Set("Range", rng)
+	}
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		log.Printf("Could not fetch %s from origin: %v", p, err)
+		http.Error(w, "Bad gateway", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if s.verbose {
+		log.Printf("Proxied %s from %s: %s", p, target, resp.Status)
+	}
+	for _, name := range proxiedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
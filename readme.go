@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark"
+)
+
+const maxReadmeChars = 4096
+
+// readmeNames are the files shown above a listing, in order of preference.
+var readmeNames = []string{"README.md", "readme.md", "README.txt", "README"}
+
+// readme renders the first 4096 characters of the README among files, the
+// entries of the directory dirPath: Markdown as HTML, anything else as
+// preformatted text.
+func (s *server) readme(dirPath string, files []FileInfo) (name string, body template.HTML) {
+	for _, candidate := range readmeNames {
+		for _, f := range files {
+			if f.Name == candidate && !f.IsDir && !f.IsBrokenSymlink {
+				name = f.Name
+			}
+		}
+		if name != "" {
+			break
+		}
+	}
+	if name == "" {
+		return "", ""
+	}
+
+	f, err := s.root.Open(path.Join(dirPath, name))
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxReadmeChars*4))
+	if err != nil {
+		return "", ""
+	}
+	if text := []rune(string(data)); len(text) > maxReadmeChars {
+		data = []byte(string(text[:maxReadmeChars]))
+	}
+
+	if strings.EqualFold(path.Ext(name), ".md") {
+		var buf bytes.Buffer
+		if err := goldmark.Convert(data, &buf); err == nil {
+			return name, template.HTML(buf.String())
+		}
+	}
+	return name, template.HTML("<pre>" + html.EscapeString(string(data)) + "</pre>")
+}
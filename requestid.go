@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// requestID returns the ID requestIDMiddleware gave the request.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware gives every request an ID, stored in its context and
+// sent back in the X-Request-ID header. An incoming X-Request-ID is only
+// kept when trust is set, so clients can't pass off their own IDs by default.
+func requestIDMiddleware(next http.Handler, trust bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !trust || id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// loggingResponseWriter records the status and size of a response for the
+// access log.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *loggingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *loggingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (w *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logRequests writes an access log line with the request ID for every
+// request once it has been served, and its User-Agent if userAgent is set.
+// Requests taking slow or longer are logged as warnings, and are the only
+// ones logged unless all is set.
+func logRequests(next http.Handler, userAgent, all bool, slow time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		elapsed := time.Since(start)
+		isSlow := slow > 0 && elapsed >= slow
+		if !all && !isSlow {
+			return
+		}
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+		format, args := "%s %s %s %d %d %v [%s]", []any{r.RemoteAddr, r.Method, r.URL.RequestURI(), lw.status, lw.size, elapsed, requestID(r.Context())}
+		if userAgent {
+			format, args = format+" UA: %s", append(args, r.UserAgent())
+		}
+		if isSlow {
+			format = "Warning: slow request: " + format
+		}
+		log.Printf(format, args...)
+	})
+}
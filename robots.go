@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http"
+)
+
+// serveRobots answers /robots.txt when the served directory has none of its
+// own, keeping crawlers out unless -allow-robots is set.
+func (s *server) serveRobots(w http.ResponseWriter, r *http.Request) {
+	rule := "Disallow: /"
+	if s.allowRobots {
+		rule = "Allow: /"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("User-agent: *\n" + rule + "\n"))
+}
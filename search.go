@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxSearchResults   = 200
+	maxSearchFileBytes = 1 << 20
+	maxSnippetBytes    = 200
+)
+
+var errSearchDone = errors.New("search done")
+
+type searchResult struct {
+	Path      string    `json:"path"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	MatchType string    `json:"match_type"`
+	Line      int       `json:"line,omitempty"`
+	Text      string    `json:"text,omitempty"`
+}
+
+// contentMatch is a line of a file that matched a content search.
+type contentMatch struct {
+	line int
+	text string
+}
+
+// handleSearch walks the whole served tree and streams the entries whose
+// name (or, with content=true, text content) matches q as a JSON array.
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := query.Get("q")
+	if s.listingDisabled("/") {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if q == "" {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+
+	// find returns the start and end of the first match in b, or nil
+	var find func([]byte) []int
+	if query.Get("regex") == "true" {
+		re, err := regexp.Compile(q)
+		if err != nil {
+			http.Error(w, "Invalid regular expression: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		find = re.FindIndex
+	} else if query.Get("case_sensitive") == "true" {
+		find = indexFinder([]byte(q), false)
+	} else {
+		find = indexFinder([]byte(q), true)
+	}
+
+	searchContent := query.Get("content") == "true"
+	var exts map[string]bool
+	if e := query.Get("ext"); e != "" {
+		exts = map[string]bool{}
+		for _, ext := range strings.Split(e, ",") {
+			exts[strings.ToLower(strings.TrimSpace(ext))] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	flusher, _ := w.(http.Flusher)
+	io.WriteString(w, "[")
+	count := 0
+	s.walkListed("/", func(p string, info fs.FileInfo) error {
+		if r.Context().Err() != nil {
+			return r.Context().Err()
+		}
+		if !info.IsDir() && !s.passesFilter(info.Name()) {
+			return nil
+		}
+
+		result := searchResult{Path: p, Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()}
+		var results []searchResult
+		if find([]byte(info.Name())) != nil {
+			result.MatchType = "filename"
+			results = append(results, result)
+		} else if searchContent && info.Mode().IsRegular() && (exts == nil || exts[strings.ToLower(path.Ext(p))]) {
+			result.MatchType = "content"
+			for _, m := range s.contentMatches(p, find) {
+				result.Line, result.Text = m.line, m.text
+				results = append(results, result)
+			}
+		}
+
+		for _, result := range results {
+			b, _ := json.Marshal(result)
+			if count > 0 {
+				io.WriteString(w, ",")
+			}
+			w.Write(b)
+			count++
+			if count >= maxSearchResults {
+				return errSearchDone
+			}
+		}
+		if len(results) > 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	io.WriteString(w, "]\n")
+}
+
+// indexFinder returns a find function for the substring needle, ignoring
+// case if foldCase is set.
+func indexFinder(needle []byte, foldCase bool) func([]byte) []int {
+	if foldCase {
+		needle = bytes.ToLower(needle)
+	}
+	return func(b []byte) []int {
+		if foldCase {
+			// lowering keeps the length of ASCII text; for the rest the
+			// offset is close enough to place a snippet
+			b = bytes.ToLower(b)
+		}
+		i := bytes.Index(b, needle)
+		if i < 0 {
+			return nil
+		}
+		return []int{i, i + len(needle)}
+	}
+}
+
+// contentMatches returns the lines of the first MiB of the file at p that
+// match, each cut to a snippet around the match. Files with a NUL byte in
+// their first 512 bytes are treated as binary and never match.
+func (s *server) contentMatches(p string, find func([]byte) []int) []contentMatch {
+	f, err := s.root.Open(p)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxSearchFileBytes))
+	if err != nil {
+		return nil
+	}
+	if bytes.IndexByte(data[:min(len(data), 512)], 0) >= 0 {
+		return nil
+	}
+	var matches []contentMatch
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if loc := find(line); loc != nil {
+			matches = append(matches, contentMatch{line: i + 1, text: snippet(line, loc[0], loc[1])})
+		}
+	}
+	return matches
+}
+
+// snippet cuts line to at most maxSnippetBytes centred on the match from
+// start to end, without splitting a UTF-8 character, and marks the cuts
+// with an ellipsis.
+func snippet(line []byte, start, end int) string {
+	if len(line) <= maxSnippetBytes {
+		return string(bytes.TrimSpace(line))
+	}
+	mid := min((start+end)/2, len(line))
+	from := max(mid-maxSnippetBytes/2, 0)
+	to := min(from+maxSnippetBytes, len(line))
+	from = max(to-maxSnippetBytes, 0)
+	for from > 0 && !utf8.RuneStart(line[from]) {
+		from++
+	}
+	for to < len(line) && !utf8.RuneStart(line[to]) {
+		to--
+	}
+	text := string(bytes.TrimSpace(line[from:to]))
+	if from > 0 {
+		text = "…" + text
+	}
+	if to < len(line) {
+		text += "…"
+	}
+	return text
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// server serves files from root and renders its own directory listings.
+type server struct {
+	rootDir    string
+	root       http.FileSystem
+	fileServer http.Handler
+	listing    *listingTemplate
+
+	// layerDirs are the directories root is made of, upper -overlay layers
+	// first
+	layerDirs []string
+
+	listingCache *listingCache
+	watcher      *dirWatcher
+	// live is cancelled by stopLive to end the live update streams
+	live         context.Context
+	stopLive     context.CancelFunc
+	verbose      bool
+	audioMeta    bool
+	throttleKBps int
+	feedTitle    string
+	indexFile    string
+
+	sitemapExclude []string
+
+	disableListing      bool
+	disableListingPaths []string
+	blocklist           blocklistFS
+	maxDirDepth         int
+
+	downloads  *downloadCounter
+	waveforms  waveforms
+	blurhashes blurhashes
+
+	forceAttachment bool
+	attachmentExts  map[string]bool
+
+	adminToken string
+	audit      *auditLog
+	shares     *shareStore
+	signer     *urlSigner
+
+	spa                bool
+	spaPassthroughExts map[string]bool
+
+	maxListingFiles int
+	proxyOrigin     string
+
+	hideExtensions  bool
+	shownExtensions map[string]bool
+
+	filters   []string
+	cspMode   string
+	urlPrefix string
+
+	noPDFViewer              bool
+	noReadme                 bool
+	tags                     bool
+	allowRobots              bool
+	allowContentTypeOverride bool
+}
+
+func newServer(rootDir string, root http.FileSystem, listing *listingTemplate, verbose bool) *server {
+	live, stopLive := context.WithCancel(context.Background())
+	return &server{
+		rootDir:    rootDir,
+		layerDirs:  []string{rootDir},
+		root:       root,
+		fileServer: http.FileServer(root),
+		listing:    listing,
+		verbose:    verbose,
+		live:       live,
+		stopLive:   stopLive,
+
+		maxDirDepth: -1,
+	}
+}
+
+func (s *server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	upath := path.Clean("/" + r.URL.Path)
+	if s.throttleKBps > 0 {
+		w = newThrottledWriter(w, r, s.throttleKBps)
+	}
+
+	// anything that is not a directory (or needs a redirect) goes to the file server
+	f, err := s.root.Open(upath)
+	if errors.Is(err, errTooDeep) {
+		http.Error(w, "Maximum directory depth exceeded.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		if archive, inner, ok := s.splitArchivePath(upath); ok {
+			s.serveArchive(w, r, archive, inner)
+			return
+		}
+		// robots.txt and sitemap.xml from the served directory win over
+		// the built-in ones
+		switch upath {
+		case "/robots.txt":
+			s.serveRobots(w, r)
+			return
+		case "/sitemap.xml":
+			s.serveSitemap(w, r)
+			return
+		}
+		if s.proxyOrigin != "" && errors.Is(err, fs.ErrNotExist) && !strings.HasPrefix(upath, "/_") {
+			s.reverseProxyFallback(w, r, upath)
+			return
+		}
+		// only missing paths are client side routes; refused ones stay refused
+		if s.spa && errors.Is(err, fs.ErrNotExist) && s.spaRoute(upath) && s.serveSPAIndex(w, r, upath) {
+			return
+		}
+		s.fileServer.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err == nil && !info.IsDir() {
+		s.serveFile(w, r, upath, f, info)
+		return
+	}
+	if err != nil || !strings.HasSuffix(r.URL.Path, "/") {
+		s.fileServer.ServeHTTP(w, r)
+		return
+	}
+
+	// show the index file in place of a listing
+	if s.indexFile != "" && s.serveIndexFile(w, r, upath) {
+		return
+	}
+
+	if s.listingDisabled(upath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if format := r.URL.Query().Get("playlist"); format != "" {
+		s.servePlaylistFile(w, r, upath, f, format)
+		return
+	}
+	switch r.URL.Query().Get("view") {
+	case "playlist":
+		s.renderPlaylist(w, r, upath, f)
+	case "slideshow":
+		s.renderSlideshow(w, r, upath, f)
+	default:
+		s.renderDirectoryListing(w, r, upath, f)
+	}
+}
+
+// serveFile serves the file f at p, adjusting headers or showing a viewer
+// page as the flags ask for.
+func (s *server) serveFile(w http.ResponseWriter, r *http.Request, p string, f http.File, info fs.FileInfo) {
+	if strings.HasSuffix(r.URL.Path, "/") && isTarArchive(p) {
+		s.serveArchive(w, r, p, "/")
+		return
+	}
+	overridden := s.allowContentTypeOverride && r.URL.Query().Has("content_type")
+	if overridden && !s.overrideContentType(w, r, p) {
+		return
+	}
+	if s.wantsAttachment(p) {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+	} else if !overridden && s.wantsPDFViewer(w, r, p) {
+		s.renderPDFViewer(w, r, p)
+		return
+	}
+	s.countDownload(w, r, p, func(w http.ResponseWriter) {
+		if path.Base(p) == "index.html" && s.indexFile != "index.html" {
+			// the file server would redirect to the directory, which
+			// doesn't show this file
+			http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+			return
+		}
+		s.fileServer.ServeHTTP(w, r)
+	})
+}
+
+// wantsAttachment reports whether the file at p should be downloaded
+// rather than shown by the browser: with -content-disposition=attachment,
+// limited to -attachment-exts if given.
+func (s *server) wantsAttachment(p string) bool {
+	if !s.forceAttachment {
+		return false
+	}
+	return s.attachmentExts == nil || s.attachmentExts[strings.ToLower(path.Ext(p))]
+}
+
+// serveIndexFile serves the index file of dirPath, if it has one.
+func (s *server) serveIndexFile(w http.ResponseWriter, r *http.Request, dirPath string) bool {
+	index, err := s.root.Open(path.Join(dirPath, s.indexFile))
+	if err != nil {
+		return false
+	}
+	defer index.Close()
+	info, err := index.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	s.countDownload(w, r, path.Join(dirPath, s.indexFile), func(w http.ResponseWriter) {
+		http.ServeContent(w, r, info.Name(), info.ModTime(), index)
+	})
+	return true
+}
+
+// isFile reports whether p is a file in the served tree.
+func (s *server) isFile(p string) bool {
+	f, err := s.root.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	return err == nil && !info.IsDir()
+}
+
+// urlPath is the escaped URL path of the served path p, below -prefix.
+func (s *server) urlPath(p string) string {
+	return s.urlPrefix + (&url.URL{Path: p}).EscapedPath()
+}
+
+// isDir reports whether p is a directory in the served tree.
+func (s *server) isDir(p string) bool {
+	f, err := s.root.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	return err == nil && info.IsDir()
+}
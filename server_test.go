@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves a directory holding hello.txt, next to a secret.txt
+// that must never be served.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	if err := os.MkdirAll(filepath.Join(root, "foo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(root, http.Dir(root), newListingTemplate(), false)
+	ts := httptest.NewServer(http.HandlerFunc(s.handleRequest))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestPathTraversal(t *testing.T) {
+	ts := newTestServer(t)
+	for _, p := range []string{
+		"/../etc/passwd",
+		"/..%2Fetc%2Fpasswd",
+		"/%2e%2e/etc/passwd",
+		"/foo/../../etc/passwd",
+		"/../secret.txt",
+		"/..%2Fsecret.txt",
+		"/%2e%2e/secret.txt",
+		"/%2e%2e%2fsecret.txt",
+		"/foo/../../secret.txt",
+	} {
+		res, err := http.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", p, res.StatusCode)
+		}
+		if strings.Contains(string(body), "secret") {
+			t.Errorf("GET %s served a file outside the root", p)
+		}
+	}
+}
+
+func TestHandleRequestBasic(t *testing.T) {
+	ts := newTestServer(t)
+
+	res, err := http.Get(ts.URL + "/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("GET /hello.txt: status %d, want 200", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("GET /hello.txt: Content-Type %q, want text/plain; charset=utf-8", ct)
+	}
+	if string(body) != "hello" {
+		t.Errorf("GET /hello.txt: body %q, want hello", body)
+	}
+
+	res, err = http.Get(ts.URL + "/missing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /missing.txt: status %d, want 404", res.StatusCode)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// share is a link to a single file, handed out by POST /_share.
+type share struct {
+	Path         string     `json:"path"`
+	Created      time.Time  `json:"created"`
+	Expires      *time.Time `json:"expires,omitempty"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+}
+
+func (sh share) expired() bool {
+	return sh.Expires != nil && time.Now().After(*sh.Expires)
+}
+
+func (sh share) checkPassword(password string) bool {
+	if sh.PasswordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(sh.PasswordHash), []byte(password)) == nil
+}
+
+// shareStore keeps share links by token, saved to file as JSON after every
+// change when file is set.
+type shareStore struct {
+	file string
+
+	mu     sync.Mutex
+	shares map[string]share
+}
+
+func loadShareStore(file string) (*shareStore, error) {
+	st := &shareStore{file: file, shares: map[string]share{}}
+	if file == "" {
+		return st, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &st.shares); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// save writes the store next to its file first so a crash never leaves a
+// half written file behind.
+func (st *shareStore) save() error {
+	if st.file == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(st.shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(st.file, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to file and renames
+// it into place, so readers never see a half written file.
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (st *shareStore) add(token string, sh share) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	// drop links that have run out so the store doesn't grow forever
+	for t, old := range st.shares {
+		if old.expired() && time.Since(*old.Expires) > 24*time.Hour {
+			delete(st.shares, t)
+		}
+	}
+	st.shares[token] = sh
+	return st.save()
+}
+
+func (st *shareStore) get(token string) (share, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sh, ok := st.shares[token]
+	return sh, ok
+}
+
+type shareRequest struct {
+	Path          string `json:"path"`
+	ExpirySeconds int64  `json:"expiry_seconds"`
+	Password      string `json:"password"`
+}
+
+type shareResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+type shareInfo struct {
+	Token       string     `json:"token"`
+	Path        string     `json:"path"`
+	URL         string     `json:"url"`
+	Created     time.Time  `json:"created"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	HasPassword bool       `json:"has_password"`
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleShare creates share links (POST) and lists the ones still valid
+// (GET). Both need the admin token.
+func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if !bearerAuthorized(r, s.adminToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.shares.mu.Lock()
+		list := []shareInfo{}
+		for token, sh := range s.shares.shares {
+			if !sh.expired() {
+				list = append(list, shareInfo{
+					Token:       token,
+					Path:        sh.Path,
+					URL:         s.urlPrefix + "/_dl/" + token,
+					Created:     sh.Created,
+					Expires:     sh.Expires,
+					HasPassword: sh.PasswordHash != "",
+				})
+			}
+		}
+		s.shares.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodPost:
+		var req shareRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ExpirySeconds < 0 {
+			http.Error(w, "expiry_seconds must not be negative", http.StatusBadRequest)
+			return
+		}
+		p := path.Clean("/" + req.Path)
+		if !s.isFile(p) {
+			http.Error(w, "Only existing files can be shared", http.StatusBadRequest)
+			return
+		}
+
+		sh := share{Path: p, Created: time.Now()}
+		if req.ExpirySeconds > 0 {
+			expires := sh.Created.Add(time.Duration(req.ExpirySeconds) * time.Second)
+			sh.Expires = &expires
+		}
+		if req.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				http.Error(w, "Invalid password: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			sh.PasswordHash = string(hash)
+		}
+		token := newToken()
+		audit := s.audit.begin(r, "share", p, -1)
+		if err := s.shares.add(token, sh); err != nil {
+			log.Printf("Could not save share links: %v", err)
+			http.Error(w, "Error saving share link", http.StatusInternalServerError)
+			audit.finish(http.StatusInternalServerError)
+			return
+		}
+		audit.finish(http.StatusCreated)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(shareResponse{Token: token, URL: s.urlPrefix + "/_dl/" + token})
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDownload serves the file behind a share link. Links with a
+// password ask for it with basic auth; any user name is accepted.
+func (s *server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/_dl/")
+	sh, ok := s.shares.get(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if sh.expired() {
+		http.Error(w, "This link has expired", http.StatusGone)
+		return
+	}
+	if _, password, _ := r.BasicAuth(); !sh.checkPassword(password) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="shared file"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.serveAttachment(w, r, sh.Path)
+}
+
+// serveAttachment serves the file at p as a download that isn't cached.
+func (s *server) serveAttachment(w http.ResponseWriter, r *http.Request, p string) {
+	f, err := s.root.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.Name()}))
+	s.countDownload(w, r, p, func(w http.ResponseWriter) {
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownRetryAfter is the Retry-After, in seconds, sent while draining.
+const shutdownRetryAfter = 30
+
+var shutdownTemplate = template.Must(template.New("shutdown").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+<title>Service unavailable</title>
+<style>
+body { margin: 0; min-height: 100vh; display: flex; align-items: center; justify-content: center; background: #f4f4f4; font-family: sans-serif; color: #333; }
+main { max-width: 480px; padding: 24px 32px; background: #fff; border-top: 4px solid #e90; box-shadow: 0 1px 4px rgba(0, 0, 0, 0.15); }
+</style>
+</head>
+<body>
+<main>
+<h1>Service unavailable</h1>
+<p>{{.}}</p>
+</main>
+</body>
+</html>
+`))
+
+// drainHandler serves next until drain is called by a graceful shutdown,
+// then answers every request still arriving with 503 and -shutdown-message.
+type drainHandler struct {
+	next     http.Handler
+	message  string
+	draining atomic.Bool
+	active   atomic.Int64
+}
+
+// drain starts answering with the shutdown message and waits until the
+// requests already being served by next are done, or ctx is.
+func (h *drainHandler) drain(ctx context.Context) {
+	h.draining.Store(true)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for h.active.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *drainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.draining.Load() {
+		h.active.Add(1)
+		defer h.active.Add(-1)
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(shutdownRetryAfter))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "close")
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]any{"message": h.message, "retry_after": shutdownRetryAfter})
+		return
+	}
+	var buf bytes.Buffer
+	shutdownTemplate.Execute(&buf, h.message)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write(buf.Bytes())
+}
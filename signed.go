@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minSecretKeyLength = 32
+
+// urlSigner makes and checks /_dl/signed/ tokens: the path and expiry time
+// together with an HMAC over both.
+type urlSigner struct {
+	key []byte
+}
+
+func newURLSigner(secret string) *urlSigner {
+	// derive the key so the secret can't be used for anything else
+	key := sha256.Sum256([]byte("simplehttpserver signed download:" + secret))
+	return &urlSigner{key: key[:]}
+}
+
+func (u *urlSigner) mac(p string, expires int64) []byte {
+	m := hmac.New(sha256.New, u.key)
+	m.Write([]byte(p + "\x00" + strconv.FormatInt(expires, 10)))
+	return m.Sum(nil)
+}
+
+func (u *urlSigner) sign(p string, expires time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(p)) + "." +
+		strconv.FormatInt(expires.Unix(), 10) + "." +
+		hex.EncodeToString(u.mac(p, expires.Unix()))
+}
+
+// verify returns the path and expiry time of a token whose HMAC matches.
+func (u *urlSigner) verify(token string) (string, time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, false
+	}
+	p, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	sum, err := hex.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sum, u.mac(string(p), expires)) {
+		return "", time.Time{}, false
+	}
+	return string(p), time.Unix(expires, 0), true
+}
+
+type signResponse struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// handleSign returns a signed download URL for ?path= that works for ?ttl=
+// seconds (an hour by default). It needs the admin token.
+func (s *server) handleSign(w http.ResponseWriter, r *http.Request) {
+	if !bearerAuthorized(r, s.adminToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	p := path.Clean("/" + query.Get("path"))
+	if !s.isFile(p) {
+		http.Error(w, "Only existing files can be signed", http.StatusBadRequest)
+		return
+	}
+	ttl := int64(3600)
+	if t := query.Get("ttl"); t != "" {
+		var err error
+		if ttl, err = strconv.ParseInt(t, 10, 64); err != nil || ttl <= 0 {
+			http.Error(w, "ttl must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+	}
+
+	expires := time.Now().Add(time.Duration(ttl) * time.Second).Truncate(time.Second)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(signResponse{URL: s.urlPrefix + "/_dl/signed/" + s.signer.sign(p, expires), Expires: expires})
+}
+
+// handleSignedDownload serves the file a valid, unexpired signed URL was
+// made for.
+func (s *server) handleSignedDownload(w http.ResponseWriter, r *http.Request) {
+	p, expires, ok := s.signer.verify(strings.TrimPrefix(r.URL.Path, "/_dl/signed/"))
+	if !ok {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().After(expires) {
+		http.Error(w, "This link expired at "+expires.UTC().Format(http.TimeFormat), http.StatusGone)
+		return
+	}
+	s.serveAttachment(w, r, p)
+}
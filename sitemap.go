@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const maxSitemapURLs = 50000
+
+var errSitemapFull = errors.New("sitemap full")
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+}
+
+// sitemapExcluded reports whether the file at p matches one of the
+// -sitemap-exclude patterns, either by name or by its whole path.
+func (s *server) sitemapExcluded(p string) bool {
+	for _, pattern := range s.sitemapExclude {
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// serveSitemap answers /sitemap.xml when the served directory has none of
+// its own with a sitemap of every file that isn't hidden or excluded.
+func (s *server) serveSitemap(w http.ResponseWriter, r *http.Request) {
+	base := baseURL(r)
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	s.walkListed("/", func(p string, info fs.FileInfo) error {
+		if r.Context().Err() != nil {
+			return r.Context().Err()
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || s.sitemapExcluded(p) || !s.passesFilter(info.Name()) {
+			return nil
+		}
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:        base + s.urlPath(p),
+			LastMod:    info.ModTime().UTC().Format(time.RFC3339),
+			ChangeFreq: "daily",
+		})
+		if len(set.URLs) >= maxSitemapURLs {
+			return errSitemapFull
+		}
+		return nil
+	})
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(set)
+}
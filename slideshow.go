@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type slide struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type slideshowData struct {
+	Path     string
+	URL      string
+	Slides   []slide
+	Interval int
+	Nonce    string
+}
+
+var slideshowTemplate = template.Must(template.New("slideshow").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width">
+<title>Slideshow for {{.Path}}</title>
+<style{{with .Nonce}} nonce="{{.}}"{{end}}>
+html, body { margin: 0; height: 100%; background: #000; color: #eee; font-family: sans-serif; }
+#stage { position: fixed; top: 0; left: 0; right: 0; bottom: 90px; display: flex; align-items: center; justify-content: center; }
+#stage img { max-width: 100%; max-height: 100%; object-fit: contain; }
+.nav { position: fixed; top: 50%; transform: translateY(-50%); font-size: 48px; color: #eee; background: none; border: 0; cursor: pointer; padding: 0 16px; }
+#prev { left: 0; }
+#next { right: 0; }
+#strip { position: fixed; left: 0; right: 0; bottom: 0; height: 90px; overflow-x: auto; white-space: nowrap; background: #111; }
+#strip img { height: 70px; margin: 10px 4px; opacity: 0.5; cursor: pointer; }
+#strip img.current { opacity: 1; outline: 2px solid #fff; }
+#empty { padding: 16px; }
+#empty a { color: #9cf; }
+</style>
+</head>
+<body>
+{{- if .Slides}}
+<div id="stage"><img id="slide" alt=""></div>
+<button class="nav" id="prev" aria-label="Previous">&#8249;</button>
+<button class="nav" id="next" aria-label="Next">&#8250;</button>
+<div id="strip"></div>
+{{- else}}
+<p id="empty">No images in this directory. <a href="{{.URL}}">Back to listing</a></p>
+{{- end}}
+<script{{with .Nonce}} nonce="{{.}}"{{end}}>
+(function () {
+  var slides = {{.Slides}};
+  if (!slides || !slides.length) return;
+  var interval = {{.Interval}} * 1000;
+  var listing = {{.URL}};
+  var img = document.getElementById("slide");
+  var strip = document.getElementById("strip");
+  var current = -1;
+  var timer;
+
+  slides.forEach(function (s, i) {
+    var thumb = document.createElement("img");
+    thumb.src = s.url;
+    thumb.alt = s.name;
+    thumb.loading = "lazy";
+    thumb.onclick = function () { go(i); };
+    strip.appendChild(thumb);
+  });
+
+  function indexOf(name) {
+    for (var i = 0; i < slides.length; i++) {
+      if (slides[i].name === name) return i;
+    }
+    return 0;
+  }
+
+  function show(i) {
+    current = i;
+    img.src = slides[i].url;
+    img.alt = slides[i].name;
+    document.title = slides[i].name;
+    Array.prototype.forEach.call(strip.children, function (t, j) {
+      t.className = j === i ? "current" : "";
+    });
+    strip.children[i].scrollIntoView({block: "nearest", inline: "center"});
+    // preload the next image so it is ready when the timer fires
+    new Image().src = slides[(i + 1) % slides.length].url;
+    clearTimeout(timer);
+    timer = setTimeout(function () { go(current + 1); }, interval);
+  }
+
+  // every transition becomes a history entry, so back steps through slides
+  function go(i) {
+    i = (i + slides.length) % slides.length;
+    location.hash = encodeURIComponent(slides[i].name);
+  }
+
+  function fromHash() {
+    var i = indexOf(decodeURIComponent(location.hash.slice(1)));
+    if (i !== current) show(i);
+  }
+
+  document.getElementById("prev").onclick = function () { go(current - 1); };
+  document.getElementById("next").onclick = function () { go(current + 1); };
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "Escape") location.href = listing;
+    else if (e.key === "ArrowLeft") go(current - 1);
+    else if (e.key === "ArrowRight") go(current + 1);
+  });
+  window.addEventListener("hashchange", fromHash);
+  fromHash();
+})();
+</script>
+</body>
+</html>
+`))
+
+// renderSlideshow renders a fullscreen page that cycles through the images
+// of dir.
+func (s *server) renderSlideshow(w http.ResponseWriter, r *http.Request, dirPath string, dir http.File) {
+	files, ok := s.listFilteredFiles(w, r, dirPath, dir)
+	if !ok {
+		return
+	}
+
+	interval, err := strconv.Atoi(r.URL.Query().Get("interval"))
+	if err != nil {
+		interval = 5
+	}
+	data := slideshowData{
+		Path:     dirPath,
+		URL:      s.urlPath(dirPath),
+		Interval: min(max(interval, 1), 60),
+	}
+	if dirPath != "/" {
+		data.Path += "/"
+		data.URL += "/"
+	}
+	for _, f := range files {
+		if f.IsImage {
+			data.Slides = append(data.Slides, slide{Name: f.Name, URL: f.Path})
+		}
+	}
+
+	data.Nonce = s.setCSP(w)
+	var buf bytes.Buffer
+	if err := slideshowTemplate.Execute(&buf, data); err != nil {
+		log.Printf("Could not render slideshow for %s: %v", dirPath, err)
+		http.Error(w, "Error rendering slideshow", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
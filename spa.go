@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// spaRoute reports whether a request for the missing path p should get the
+// single-page app's index.html. Paths below /_ belong to the server, and
+// missing files with an extension still get a 404: any extension, or only
+// those given with -spa-passthrough-exts.
+func (s *server) spaRoute(p string) bool {
+	if strings.HasPrefix(p, "/_") {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" {
+		return true
+	}
+	return s.spaPassthroughExts != nil && !s.spaPassthroughExts[ext]
+}
+
+// serveSPAIndex serves /index.html for the client side route p.
+func (s *server) serveSPAIndex(w http.ResponseWriter, r *http.Request, p string) bool {
+	index, err := s.root.Open("/index.html")
+	if err != nil {
+		return false
+	}
+	defer index.Close()
+	info, err := index.Stat()
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if s.verbose {
+		log.Printf("Serving /index.html for client side route %s", p)
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), index)
+	return true
+}
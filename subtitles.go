@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const maxSubtitleBytes = 10 << 20
+
+var srtTimestamp = regexp.MustCompile(`(\d{2}:\d{2}:\d{2}),(\d{3})`)
+
+// srtToVTT converts SubRip subtitles to WebVTT. The two only differ in the
+// header and in using a comma instead of a dot before the milliseconds.
+func srtToVTT(srt []byte) []byte {
+	srt = bytes.TrimPrefix(srt, []byte("\xef\xbb\xbf"))
+	srt = bytes.ReplaceAll(srt, []byte("\r\n"), []byte("\n"))
+
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n\n")
+	for _, line := range bytes.Split(srt, []byte("\n")) {
+		if bytes.Contains(line, []byte("-->")) {
+			line = srtTimestamp.ReplaceAll(line, []byte("$1.$2"))
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// handleSRT2VTT serves the .srt file at the path following /_srt2vtt as
+// WebVTT, so it can be used as the source of a <track> element.
+func (s *server) handleSRT2VTT(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_srt2vtt"))
+	if strings.ToLower(path.Ext(p)) != ".srt" {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := s.root.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	srt, err := io.ReadAll(io.LimitReader(f, maxSubtitleBytes))
+	if err != nil {
+		http.Error(w, "Error reading subtitles", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Write(srtToVTT(srt))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// symlinkFS refuses to open anything that resolves to a location outside
+// the served root, so symlinks can't be used to reach the rest of the disk.
+type symlinkFS struct {
+	http.FileSystem
+	root string
+}
+
+func (fsys symlinkFS) Open(name string) (http.File, error) {
+	full := filepath.Join(fsys.root, filepath.FromSlash(path.Clean("/"+name)))
+	if resolved, err := filepath.EvalSymlinks(full); err == nil && !withinDir(fsys.root, resolved) {
+		return nil, os.ErrNotExist
+	}
+	return fsys.FileSystem.Open(name)
+}
+
+// withinDir reports whether p is dir or somewhere below it.
+func withinDir(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkOutside returns an error if file is inside one of dirs, where it
+// could be downloaded like any other file.
+func checkOutside(file string, dirs []string) error {
+	for _, d := range dirs {
+		if withinDir(realPath(d), realPath(filepath.Dir(file))) {
+			return fmt.Errorf("%s must not be inside the served directory %s", file, d)
+		}
+	}
+	return nil
+}
+
+// resolveSymlink follows the symlink at URL path p and returns what it
+// points to: a URL path when the target is inside the layer holding the link
+// and "<external>" otherwise.
+func (s *server) resolveSymlink(p string) (string, os.FileInfo, error) {
+	layer := s.layerDir(p)
+	resolved, err := filepath.EvalSymlinks(filepath.Join(layer, filepath.FromSlash(p)))
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	root := realPath(layer)
+	if !withinDir(root, resolved) {
+		return "<external>", info, nil
+	}
+	rel, _ := filepath.Rel(root, resolved)
+	return path.Join("/", filepath.ToSlash(rel)), info, nil
+}
+
+// layerDir returns the directory p is served from: the first -overlay layer
+// that has it, or the served directory.
+func (s *server) layerDir(p string) string {
+	for _, d := range s.layerDirs {
+		if _, err := os.Lstat(filepath.Join(d, filepath.FromSlash(p))); err == nil {
+			return d
+		}
+	}
+	return s.rootDir
+}
+
+// realPath resolves any symlinks in dir, falling back to dir itself.
+func realPath(dir string) string {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		return resolved
+	}
+	return dir
+}
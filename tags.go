@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	// tagsDir is the directory next to tagged files that holds a
+	// <filename>.json list of tags for each of them.
+	tagsDir = ".tags"
+
+	maxTags      = 32
+	maxTagLength = 64
+)
+
+type tagRequest struct {
+	Path string   `json:"path"`
+	Tags []string `json:"tags"`
+}
+
+// readTags returns the tags of the file at p from its sidecar file.
+func (s *server) readTags(p string) []string {
+	f, err := s.root.Open(path.Join(path.Dir(p), tagsDir, path.Base(p)+".json"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var tags []string
+	if err := json.NewDecoder(io.LimitReader(f, 1<<16)).Decode(&tags); err != nil {
+		return nil
+	}
+	return tags
+}
+
+// addTags fills in the tags of the files of the directory dirPath, leaving
+// out the directory the tags are kept in.
+func (s *server) addTags(dirPath string, files []FileInfo) []FileInfo {
+	kept := files[:0]
+	for _, f := range files {
+		if f.IsDir && f.Name == tagsDir {
+			continue
+		}
+		if !f.IsDir {
+			f.Tags = s.readTags(path.Join(dirPath, f.Name))
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// filterTagged keeps the directories of files and the files tagged tag.
+func filterTagged(files []FileInfo, tag string) []FileInfo {
+	kept := files[:0]
+	for _, f := range files {
+		if f.IsDir || hasTag(f.Tags, tag) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// validTag reports whether tag is a short single line of printable text.
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > maxTagLength {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleTag sets the tags of a file from a POST of {"path":..,"tags":[..]}
+// with the admin token. An empty list removes them.
+func (s *server) handleTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.adminToken == "" || !bearerAuthorized(r, s.adminToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req tagRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if escapesRoot(req.Path) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	p := path.Clean("/" + req.Path)
+	if !s.isFile(p) {
+		http.Error(w, "No such file", http.StatusNotFound)
+		return
+	}
+	if len(req.Tags) > maxTags {
+		http.Error(w, "Too many tags", http.StatusBadRequest)
+		return
+	}
+	tags := []string{}
+	for _, tag := range req.Tags {
+		tag = strings.TrimSpace(tag)
+		if !validTag(tag) {
+			http.Error(w, "Invalid tag "+tag, http.StatusBadRequest)
+			return
+		}
+		if !hasTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	dir := filepath.Join(s.rootDir, filepath.FromSlash(path.Dir(p)), tagsDir)
+	file := filepath.Join(dir, path.Base(p)+".json")
+	sizeBefore := int64(-1)
+	if info, err := os.Stat(file); err == nil {
+		sizeBefore = info.Size()
+	}
+	audit := s.audit.begin(r, "tag", p, sizeBefore)
+	var err error
+	if len(tags) == 0 {
+		if err = os.Remove(file); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	} else if err = os.MkdirAll(dir, 0o755); err == nil {
+		data, _ := json.Marshal(tags)
+		err = writeFileAtomic(file, data)
+	}
+	if err != nil {
+		log.Printf("Could not save tags of %s: %v", p, err)
+		http.Error(w, "Could not save tags", http.StatusInternalServerError)
+		audit.finish(http.StatusInternalServerError)
+		return
+	}
+	audit.finish(http.StatusOK)
+
+	if s.listingCache != nil {
+		s.listingCache.invalidate(path.Dir(p))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tagRequest{Path: p, Tags: tags})
+}
+
+// handleTags serves the tags of the file following /_tags.
+func (s *server) handleTags(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_tags"))
+	if !s.isFile(p) {
+		http.NotFound(w, r)
+		return
+	}
+	tags := s.readTags(p)
+	if tags == nil {
+		tags = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tagRequest{Path: p, Tags: tags})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	dir := t.TempDir()
+	media := filepath.Join(dir, "media")
+	if err := os.MkdirAll(filepath.Join(media, "albums"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"photo.jpg", "clip.mp4", "notes.txt"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(media, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newServer(dir, http.Dir(dir), newListingTemplate(), false)
+	f, err := s.root.Open("/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/media/", nil)
+	r.Header.Set("Accept", "text/html")
+	s.renderDirectoryListing(w, r, "/media", f)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	doc, err := html.Parse(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the class of each entry's row, by the text of its link
+	links := map[string]string{}
+	parent := ""
+	var walk func(n *html.Node, rowClass string)
+	walk = func(n *html.Node, rowClass string) {
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			rowClass = attr(n, "class")
+		}
+		if n.Type == html.ElementNode && n.Data == "a" {
+			if attr(n, "id") == "parent" {
+				parent = attr(n, "href")
+			} else if n.FirstChild != nil {
+				links[n.FirstChild.Data] = rowClass
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, rowClass)
+		}
+	}
+	walk(doc, "")
+
+	for _, name := range names {
+		class, ok := links[name]
+		if !ok {
+			t.Errorf("no link to %s", name)
+		} else if strings.Contains(class, "dir") {
+			t.Errorf("%s has class %q", name, class)
+		}
+	}
+	if class, ok := links["albums/"]; !ok {
+		t.Error("no link to albums/")
+	} else if class != "dir" {
+		t.Errorf("albums/ has class %q, want dir", class)
+	}
+	if parent != "/" {
+		t.Errorf("parent link %q, want /", parent)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
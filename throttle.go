@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// throttledWriter limits how fast a response body is written.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func newThrottledWriter(w http.ResponseWriter, r *http.Request, kbps int) *throttledWriter {
+	bytesPerSec := kbps * 1024
+	return &throttledWriter{
+		ResponseWriter: w,
+		ctx:            r.Context(),
+		limiter:        rate.NewLimiter(rate.Limit(bytesPerSec), min(bytesPerSec, 32*1024)),
+	}
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := min(len(b), w.limiter.Burst())
+		if err := w.limiter.WaitN(w.ctx, chunk); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(b[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[chunk:]
+	}
+	return written, nil
+}
+
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+)
+
+// walkFS calls fn for every entry below dir in fsys, depth first and in name
+// order. Directories that cannot be read are skipped, as are directories fn
+// returns fs.SkipDir for; any other error returned by fn stops the walk and
+// is returned.
+func walkFS(fsys http.FileSystem, dir string, fn func(p string, info fs.FileInfo) error) error {
+	f, err := fsys.Open(dir)
+	if err != nil {
+		return nil
+	}
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		if err := fn(p, entry); err == fs.SkipDir && entry.IsDir() {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if err := walkFS(fsys, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
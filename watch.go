@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dirEvent is sent to clients following a directory.
+type dirEvent struct {
+	Event string `json:"event"`
+	Name  string `json:"name"`
+	IsDir bool   `json:"is_dir"`
+}
+
+// dirWatcher shares one fsnotify watcher between everyone following
+// directory changes. A directory is only watched while it has subscribers.
+type dirWatcher struct {
+	watcher *fsnotify.Watcher
+
+	mu   sync.Mutex
+	subs map[string]map[chan string]bool
+}
+
+func newDirWatcher() (*dirWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	d := &dirWatcher{watcher: watcher, subs: map[string]map[chan string]bool{}}
+	go d.run()
+	return d, nil
+}
+
+func (d *dirWatcher) run() {
+	for {
+		select {
+		case ev, ok := <-d.watcher.Events:
+			if !ok {
+				return
+			}
+			d.mu.Lock()
+			for ch := range d.subs[filepath.Dir(ev.Name)] {
+				// a full channel already has a refresh pending, so the
+				// name can be dropped
+				select {
+				case ch <- filepath.Base(ev.Name):
+				default:
+				}
+			}
+			d.mu.Unlock()
+		case _, ok := <-d.watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// subscribe returns a channel receiving the names of entries that changed in
+// any of dirs, and a function to call once the caller is no longer
+// interested. Dirs that can't be watched, like those missing from an
+// -overlay layer, are skipped; it fails only when none can be.
+func (d *dirWatcher) subscribe(dirs ...string) (<-chan string, func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch := make(chan string, 64)
+	var watched []string
+	var firstErr error
+	for _, dir := range dirs {
+		if d.subs[dir] == nil {
+			if err := d.watcher.Add(dir); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			d.subs[dir] = map[chan string]bool{}
+		}
+		d.subs[dir][ch] = true
+		watched = append(watched, dir)
+	}
+	if len(watched) == 0 {
+		return nil, nil, firstErr
+	}
+
+	unsubscribe := func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, dir := range watched {
+			delete(d.subs[dir], ch)
+			if len(d.subs[dir]) == 0 {
+				delete(d.subs, dir)
+				d.watcher.Remove(dir)
+			}
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// visibleNames returns the entries of dirPath as a listing would show them,
+// mapped to whether they are directories.
+func (s *server) visibleNames(dirPath string) map[string]bool {
+	names := map[string]bool{}
+	dir, err := s.root.Open(dirPath)
+	if err != nil {
+		return names
+	}
+	defer dir.Close()
+	entries, _ := readDirEntries(dir)
+	for _, entry := range entries {
+		names[entry.Name()] = entry.IsDir()
+	}
+	return names
+}
+
+// followDir calls send with the changes to dirPath until ctx is done, the
+// server shuts down or send fails, and heartbeat every 30 seconds in
+// between. Changes are worked out by comparing what the listing shows before
+// and after a burst of file system events, so entries a listing would hide
+// are never reported.
+func (s *server) followDir(ctx context.Context, dirPath string, send func([]dirEvent) error, heartbeat func() error) error {
+	var dirs []string
+	for _, d := range s.layerDirs {
+		dirs = append(dirs, filepath.Join(d, filepath.FromSlash(dirPath)))
+	}
+	changes, unsubscribe, err := s.watcher.subscribe(dirs...)
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.live, cancel)
+	defer stop()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	visible := s.visibleNames(dirPath)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case name := <-changes:
+			touched := map[string]bool{name: true}
+			burst := time.After(100 * time.Millisecond)
+		collect:
+			for {
+				select {
+				case name := <-changes:
+					touched[name] = true
+				case <-burst:
+					break collect
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			if s.listingCache != nil {
+				s.listingCache.invalidate(dirPath)
+			}
+			now := s.visibleNames(dirPath)
+			events := diffDirEvents(visible, now, touched)
+			visible = now
+			if len(events) > 0 {
+				if err := send(events); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func diffDirEvents(before, after map[string]bool, touched map[string]bool) []dirEvent {
+	var events []dirEvent
+	for name, isDir := range after {
+		if _, ok := before[name]; !ok {
+			events = append(events, dirEvent{Event: "created", Name: name, IsDir: isDir})
+		} else if touched[name] {
+			events = append(events, dirEvent{Event: "modified", Name: name, IsDir: isDir})
+		}
+	}
+	for name, isDir := range before {
+		if _, ok := after[name]; !ok {
+			events = append(events, dirEvent{Event: "deleted", Name: name, IsDir: isDir})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
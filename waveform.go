@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWaveformPoints = 200
+	maxWaveformPoints     = 2000
+	maxCachedWaveforms    = 64
+
+	// ffmpeg decodes to 8000 mono samples a second, and the peaks of every
+	// 80 of them (10 ms) are kept before they are reduced to the points
+	waveformSampleRate = 8000
+	waveformBlock      = 80
+	waveformTimeout    = 2 * time.Minute
+)
+
+var errNoFFmpeg = errors.New("ffmpeg not found")
+
+// waveformJobs bounds how many ffmpeg processes decode audio at once.
+var waveformJobs = make(chan struct{}, runtime.NumCPU())
+
+// waveforms caches the decoded peaks of audio files by path and
+// modification time, so any number of points is reduced from one decode.
+// Each is decoded once; concurrent requests wait for it.
+type waveforms struct {
+	mu      sync.Mutex
+	entries map[string]*waveform
+}
+
+type waveform struct {
+	done   chan struct{}
+	blocks []float32
+	err    error
+}
+
+// handleWaveform serves the waveform of the audio file at the path
+// following /_waveform as a JSON array of ?points= peaks between 0 and 1.
+func (s *server) handleWaveform(w http.ResponseWriter, r *http.Request) {
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_waveform"))
+	if !isAudioFile(p) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := s.root.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	info, err := f.Stat()
+	f.Close()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	points := defaultWaveformPoints
+	if q := r.URL.Query().Get("points"); q != "" {
+		if points, err = strconv.Atoi(q); err != nil || points < 1 || points > maxWaveformPoints {
+			http.Error(w, "points must be between 1 and "+strconv.Itoa(maxWaveformPoints), http.StatusBadRequest)
+			return
+		}
+	}
+
+	key := p + "@" + strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	wf := s.waveforms.get(key, func() ([]float32, error) { return s.decodePeaks(p) })
+	select {
+	case <-wf.done:
+	case <-r.Context().Done():
+		return
+	}
+	switch {
+	case errors.Is(wf.err, errNoFFmpeg):
+		http.Error(w, "Waveforms need ffmpeg, which is not installed", http.StatusNotImplemented)
+		return
+	case wf.err != nil:
+		log.Printf("Could not compute waveform of %s: %v", p, wf.err)
+		http.Error(w, "Could not decode audio", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	json.NewEncoder(w).Encode(reducePeaks(wf.blocks, points))
+}
+
+// get returns the cached waveform for key, starting compute in the
+// background when there is none yet.
+func (c *waveforms) get(key string, compute func() ([]float32, error)) *waveform {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if wf, ok := c.entries[key]; ok {
+		return wf
+	}
+	if c.entries == nil || len(c.entries) >= maxCachedWaveforms {
+		c.entries = map[string]*waveform{}
+	}
+	wf := &waveform{done: make(chan struct{})}
+	c.entries[key] = wf
+	go func() {
+		defer close(wf.done)
+		waveformJobs <- struct{}{}
+		wf.blocks, wf.err = compute()
+		<-waveformJobs
+		if wf.err != nil {
+			// let the next request try again
+			c.mu.Lock()
+			delete(c.entries, key)
+			c.mu.Unlock()
+		}
+	}()
+	return wf
+}
+
+// decodePeaks decodes the audio file at p with ffmpeg and returns the peak
+// of every 10 ms of it.
+func (s *server) decodePeaks(p string) ([]float32, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, errNoFFmpeg
+	}
+	f, err := s.root.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), waveformTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, ffmpeg, "-v", "error", "-i", "pipe:0",
+		"-f", "f32le", "-ac", "1", "-ar", strconv.Itoa(waveformSampleRate), "pipe:1")
+	cmd.Stdin = f
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var blocks []float32
+	rd := bufio.NewReader(out)
+	var sample [4]byte
+	var peak float32
+	n := 0
+	for {
+		if _, err := io.ReadFull(rd, sample[:]); err != nil {
+			break
+		}
+		peak = max(peak, float32(math.Abs(float64(math.Float32frombits(binary.LittleEndian.Uint32(sample[:]))))))
+		if n++; n == waveformBlock {
+			blocks = append(blocks, peak)
+			peak, n = 0, 0
+		}
+	}
+	if n > 0 {
+		blocks = append(blocks, peak)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// reducePeaks reduces blocks to the peaks of points equal slices, scaled so
+// the loudest is 1.
+func reducePeaks(blocks []float32, points int) []float64 {
+	result := make([]float64, points)
+	loudest := 0.0
+	if len(blocks) > 0 {
+		for i := range result {
+			from := i * len(blocks) / points
+			to := max((i+1)*len(blocks)/points, from+1)
+			for _, b := range blocks[from:min(to, len(blocks))] {
+				result[i] = max(result[i], float64(b))
+			}
+			loudest = max(loudest, result[i])
+		}
+	}
+	if loudest > 0 {
+		for i := range result {
+			result[i] = math.Round(result[i]/loudest*1000) / 1000
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path"
+
+	"golang.org/x/net/webdav"
+)
+
+// davFS exposes the served tree to WebDAV clients, read only.
+type davFS struct {
+	srv *server
+}
+
+func (d davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (d davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return nil, os.ErrPermission
+	}
+	name = path.Clean("/" + name)
+	f, err := d.srv.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return davFile{File: f, srv: d.srv, name: name}, nil
+}
+
+func (d davFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (d davFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (d davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	f, err := d.srv.root.Open(path.Clean("/" + name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// davFile is a file of davFS. Directories with listings disabled can't be
+// read.
+type davFile struct {
+	http.File
+	srv  *server
+	name string
+}
+
+func (f davFile) Readdir(count int) ([]os.FileInfo, error) {
+	if f.srv.listingDisabled(f.name) {
+		return nil, os.ErrPermission
+	}
+	return f.File.Readdir(count)
+}
+
+func (f davFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// davReadMethods are the WebDAV methods that don't change anything.
+var davReadMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+}
+
+// newWebDAVHandler serves the tree over WebDAV below prefix. Requests that
+// would change it get 405 Method Not Allowed.
+func (s *server) newWebDAVHandler(prefix string) http.Handler {
+	dav := &webdav.Handler{
+		Prefix:     s.urlPrefix + prefix,
+		FileSystem: davFS{srv: s},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil && s.verbose {
+				log.Printf("WebDAV %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !davReadMethods[r.Method] {
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS, PROPFIND")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// the WebDAV handler writes hrefs from the path it strips
+		r.URL.Path = s.urlPrefix + r.URL.Path
+		dav.ServeHTTP(w, r)
+	})
+}
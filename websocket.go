@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{}
+
+// wsPongTimeout is how long the socket lives without a pong; the pings go
+// out every 30 seconds.
+const wsPongTimeout = 75 * time.Second
+
+// handleWebSocket pushes the changes to the directory following /_ws to the
+// client as JSON messages, one per changed entry.
+func (s *server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	dirPath := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/_ws"))
+	if !s.isDir(dirPath) {
+		http.NotFound(w, r)
+		return
+	}
+	if s.listingDisabled(dirPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if s.watcher == nil {
+		http.Error(w, "Directory watching is not available", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// the connection still has the server's read and write timeouts, which
+	// would close it after a minute; pongs keep it open instead
+	conn.NetConn().SetDeadline(time.Time{})
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	// the client never sends anything, reading just notices when it leaves
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	s.followDir(ctx, dirPath, func(events []dirEvent) error {
+		for _, ev := range events {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+	})
+}